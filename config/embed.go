@@ -0,0 +1,9 @@
+// Package config holds the templates shipped with KSTool
+package config
+
+import _ "embed"
+
+// BaseTemplate is the default job template, used when it can't be downloaded
+//
+//go:embed base_apply.yaml
+var BaseTemplate []byte
@@ -79,6 +79,7 @@ type Job struct {
 	Pods        string
 	GPUCount    int
 	GPUInfo     string
+	Sweep       string
 }
 
 // Add status filter mode
@@ -119,12 +120,15 @@ const (
 
 var client *kubernetes.Clientset
 
-func newClient() (*kubernetes.Clientset, error) {
+// kubeClients is shared with the job creation form in src
+var kubeClients *src.KubeClients
+
+func newRESTConfig() (*rest.Config, error) {
 	// Try in-cluster config first
 	cfg, err := rest.InClusterConfig()
 	if err == nil {
 		cfg.Timeout = 5 * time.Second
-		return kubernetes.NewForConfig(cfg)
+		return cfg, nil
 	}
 
 	// Not in a cluster: try KUBECONFIG env var or default location
@@ -143,12 +147,17 @@ func newClient() (*kubernetes.Clientset, error) {
 	}
 
 	cfg.Timeout = 5 * time.Second
-	return kubernetes.NewForConfig(cfg)
+	return cfg, nil
 }
 
-func init() {
-	var err error
-	client, err = newClient()
+func initClients() {
+	cfg, err := newRESTConfig()
+	if err == nil {
+		client, err = kubernetes.NewForConfig(cfg)
+	}
+	if err == nil {
+		kubeClients, err = src.NewKubeClients(cfg, NAMESPACE)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to create k8s client: %v\n", err)
 		os.Exit(1)
@@ -205,6 +214,7 @@ func getJobs(ctx context.Context) ([]Job, error) {
 			Pods:        fmt.Sprintf("%d pods", len(pods)),
 			GPUCount:    gpuCount,
 			GPUInfo:     gpuInfo,
+			Sweep:       j.Labels[src.SweepLabel],
 		})
 	}
 	return jobs, nil
@@ -402,7 +412,7 @@ func createTable() *tview.Table {
 		SetSelectable(true, false).
 		SetSeparator(' ')
 
-	headers := []string{"NAME", "STATUS", "COMPLETIONS", "DURATION", "AGE", "PODS", "GPU", "GPU INFO"}
+	headers := []string{"NAME", "STATUS", "COMPLETIONS", "DURATION", "AGE", "PODS", "GPU", "GPU INFO", "SWEEP"}
 	for i, h := range headers {
 		table.SetCell(0, i, tview.NewTableCell(h).
 			SetTextColor(COLOR_HEADER).
@@ -439,6 +449,7 @@ func updateTable(table *tview.Table, jobs []Job) {
 			SetTextColor(getGPUCountColor(j.GPUCount)))
 
 		table.SetCell(i+1, 7, tview.NewTableCell(j.GPUInfo).SetTextColor(getGPUColor(j.GPUInfo)))
+		table.SetCell(i+1, 8, tview.NewTableCell(j.Sweep))
 	}
 }
 
@@ -455,7 +466,16 @@ func getGPUCountColor(count int) tcell.Color {
 // ------------------------------------------------------------
 
 func main() {
+	// Subcommands such as `kstool lint` don't need the cluster
+	if len(os.Args) > 1 {
+		os.Exit(src.RunCommand(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	initClients()
+
 	ctx := context.Background()
+	// Advance pipeline runs in the background, resuming those of earlier sessions
+	src.StartPipelineExecutor(ctx, kubeClients)
 	jobs, err := getJobs(ctx)
 	if err != nil {
 		panic(err)
@@ -474,7 +494,7 @@ func main() {
 	// Filter status display
 	filterText := tview.NewTextView().
 		SetTextAlign(tview.AlignLeft).
-		SetText("(F)ilter: All | (H)ide Others | (S)ort: Age↓ | (R)efresh | (D)elete | (E)nter | (C)onfig | (N)ew Config").
+		SetText("(F)ilter: All | (H)ide Others | (S)ort: Age↓ | (R)efresh | (D)elete | (E)nter | (I)ndexes | Cr(o)nJobs | (P)ipelines | (C)onfig | (N)ew Config").
 		SetTextColor(COLOR_DEFAULT)
 	flex.AddItem(filterText, 1, 0, false)
 
@@ -649,6 +669,43 @@ func filterJobsByStatus(jobs []Job, status string) []Job {
 	return filtered
 }
 
+// filterJobsBySweep keeps the jobs submitted by one sweep
+func filterJobsBySweep(jobs []Job, sweep string) []Job {
+	var filtered []Job
+	for _, job := range jobs {
+		if job.Sweep == sweep {
+			filtered = append(filtered, job)
+		}
+	}
+	return filtered
+}
+
+// listSweeps returns the sweeps the jobs belong to, newest first
+func listSweeps(jobs []Job) []string {
+	seen := make(map[string]bool)
+	var sweeps []string
+	for _, job := range jobs {
+		if job.Sweep != "" && !seen[job.Sweep] {
+			seen[job.Sweep] = true
+			sweeps = append(sweeps, job.Sweep)
+		}
+	}
+	// Sweep IDs hold their submission time before a random suffix, so they sort by time
+	sort.Sort(sort.Reverse(sort.StringSlice(sweeps)))
+	return sweeps
+}
+
+// groupJobsBySweep moves the jobs of each sweep together, newest sweep first and jobs
+// outside any sweep last, keeping the current order within each group
+func groupJobsBySweep(jobs []Job) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].Sweep == "" || jobs[j].Sweep == "" {
+			return jobs[i].Sweep != "" && jobs[j].Sweep == ""
+		}
+		return jobs[i].Sweep > jobs[j].Sweep
+	})
+}
+
 func createDeleteModal(app *tview.Application, root *tview.Flex, ctx context.Context, jobName, jobStatus string, table *tview.Table) *tview.Flex {
 	modalFlex := tview.NewFlex().SetDirection(tview.FlexRow)
 
@@ -776,6 +833,8 @@ type CommandHandler struct {
 	currentUser   string
 	filterText    *tview.TextView
 	showOnlyUser  bool
+	sweepFilter   string
+	groupBySweep  bool
 }
 
 // NewCommandHandler creates a new CommandHandler
@@ -820,6 +879,18 @@ func (h *CommandHandler) HandleCommand(ev *tcell.EventKey) *tcell.EventKey {
 			return h.handleConfig()
 		case 'n':
 			return h.handleNewConfig()
+		case 'w':
+			return h.handleSweepFilter()
+		case 'g':
+			h.groupBySweep = !h.groupBySweep
+			h.updateTableWithFilter()
+			return nil
+		case 'i':
+			return h.handleIndexes()
+		case 'o':
+			return h.handleCronJobs()
+		case 'p':
+			return h.handlePipelines()
 		}
 	}
 	return ev
@@ -845,6 +916,25 @@ func (h *CommandHandler) handleFilter() *tcell.EventKey {
 	return nil
 }
 
+// handleSweepFilter cycles through showing all jobs and the jobs of each sweep, newest first
+func (h *CommandHandler) handleSweepFilter() *tcell.EventKey {
+	sweeps := listSweeps(h.jobs)
+	next := ""
+	for i, sweep := range sweeps {
+		if h.sweepFilter == "" {
+			next = sweeps[0]
+			break
+		}
+		if sweep == h.sweepFilter && i+1 < len(sweeps) {
+			next = sweeps[i+1]
+			break
+		}
+	}
+	h.sweepFilter = next
+	h.updateTableWithFilter()
+	return nil
+}
+
 // handleSort handles the sort command
 func (h *CommandHandler) handleSort() *tcell.EventKey {
 	h.currentSort = (h.currentSort + 1) % 8
@@ -852,6 +942,45 @@ func (h *CommandHandler) handleSort() *tcell.EventKey {
 	return nil
 }
 
+// handleIndexes shows the per-index status of the selected indexed job
+func (h *CommandHandler) handleIndexes() *tcell.EventKey {
+	row, _ := h.table.GetSelection()
+	if row == 0 { // header
+		return nil
+	}
+	jobName := h.table.GetCell(row, 0).Text
+
+	// Only the owner may retry failed indexes, as the retry job keeps the owner label
+	owned := false
+	if job, err := client.BatchV1().Jobs(NAMESPACE).Get(h.ctx, jobName, metav1.GetOptions{}); err == nil {
+		owned = job.Labels[USER_LABEL] == h.currentUser
+	}
+
+	src.ShowJobIndexes(h.app, h.ctx, kubeClients, jobName, owned, func() {
+		h.app.SetRoot(h.flex, true)
+		h.handleRefresh()
+	})
+	return nil
+}
+
+// handleCronJobs shows the CronJobs of the namespace
+func (h *CommandHandler) handleCronJobs() *tcell.EventKey {
+	src.ShowCronJobs(h.app, h.ctx, kubeClients, h.currentUser, func() {
+		h.app.SetRoot(h.flex, true)
+		h.handleRefresh()
+	})
+	return nil
+}
+
+// handlePipelines shows the pipelines and their runs
+func (h *CommandHandler) handlePipelines() *tcell.EventKey {
+	src.ShowPipelines(h.app, func() {
+		h.app.SetRoot(h.flex, true)
+		h.handleRefresh()
+	})
+	return nil
+}
+
 // handleDelete handles the delete command
 func (h *CommandHandler) handleDelete() *tcell.EventKey {
 	row, _ := h.table.GetSelection()
@@ -1059,24 +1188,15 @@ func (h *CommandHandler) handleConfig() *tcell.EventKey {
 	}
 	tmpFile.Close()
 
-	// Stop the TUI before executing vim
-	h.app.Stop()
-
-	// Execute vim in read-only mode
-	cmd := exec.Command("vim", "-R", tmpFile.Name())
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open vim: %v\n", err)
-	}
-
-	// Restart the TUI with a fresh context
-	h.app = tview.NewApplication()
-	h.app.SetRoot(h.flex, true).SetFocus(h.table)
-	if err := h.app.Run(); err != nil {
-		panic(err)
+	// Open the job in the user's editor, read-only
+	if err := src.EditFile(h.app, tmpFile.Name(), true); err != nil {
+		modal := tview.NewModal().
+			SetText(fmt.Sprintf("Error opening job config for '%s':\n%v\n\nPress OK to continue", jobName, err)).
+			AddButtons([]string{"OK"}).
+			SetDoneFunc(func(int, string) {
+				h.app.SetRoot(h.flex, true)
+			})
+		h.app.SetRoot(modal, true)
 	}
 	return nil
 }
@@ -1084,7 +1204,7 @@ func (h *CommandHandler) handleConfig() *tcell.EventKey {
 // handleNewConfig handles the new config command
 func (h *CommandHandler) handleNewConfig() *tcell.EventKey {
 	// Create new job form
-	createForm := src.NewCreateJobForm(h.app, h.ctx, func() {
+	createForm := src.NewCreateJobForm(h.app, h.ctx, kubeClients, func() {
 		// Refresh data after closing the form
 		if newJobs, err := getJobs(h.ctx); err == nil {
 			h.jobs = newJobs
@@ -1121,25 +1241,33 @@ func (h *CommandHandler) updateTableWithFilter() {
 	}
 
 	// Then apply status filter
+	filterName := "All"
 	switch h.currentFilter {
-	case FilterAll:
-		h.filterText.SetText(fmt.Sprintf("(F)ilter: All | (H)ide Others: %v | (S)ort: %s | (R)efresh | (D)elete | (E)nter | (C)onfig | (N)ew Config",
-			h.showOnlyUser, getSortText(h.currentSort)))
 	case FilterRunning:
-		filteredJobs = filterJobsByStatus(filteredJobs, "Running")
-		h.filterText.SetText(fmt.Sprintf("(F)ilter: Running | (H)ide Others: %v | (S)ort: %s | (R)efresh | (D)elete | (E)nter | (C)onfig | (N)ew Config",
-			h.showOnlyUser, getSortText(h.currentSort)))
+		filterName = "Running"
 	case FilterFailed:
-		filteredJobs = filterJobsByStatus(filteredJobs, "Failed")
-		h.filterText.SetText(fmt.Sprintf("(F)ilter: Failed | (H)ide Others: %v | (S)ort: %s | (R)efresh | (D)elete | (E)nter | (C)onfig | (N)ew Config",
-			h.showOnlyUser, getSortText(h.currentSort)))
+		filterName = "Failed"
 	case FilterPending:
-		filteredJobs = filterJobsByStatus(filteredJobs, "Pending")
-		h.filterText.SetText(fmt.Sprintf("(F)ilter: Pending | (H)ide Others: %v | (S)ort: %s | (R)efresh | (D)elete | (E)nter | (C)onfig | (N)ew Config",
-			h.showOnlyUser, getSortText(h.currentSort)))
+		filterName = "Pending"
+	}
+	if h.currentFilter != FilterAll {
+		filteredJobs = filterJobsByStatus(filteredJobs, filterName)
+	}
+
+	// Then apply sweep filter
+	sweepName := "All"
+	if h.sweepFilter != "" {
+		filteredJobs = filterJobsBySweep(filteredJobs, h.sweepFilter)
+		sweepName = h.sweepFilter
 	}
 
+	h.filterText.SetText(fmt.Sprintf("(F)ilter: %s | (H)ide Others: %v | (S)ort: %s | S(w)eep: %s | (G)roup: %v | (R)efresh | (D)elete | (E)nter | (I)ndexes | Cr(o)nJobs | (P)ipelines | (C)onfig | (N)ew Config",
+		filterName, h.showOnlyUser, getSortText(h.currentSort), sweepName, h.groupBySweep))
+
 	// Apply sorting
 	sortJobs(filteredJobs, h.currentSort)
+	if h.groupBySweep {
+		groupJobsBySweep(filteredJobs)
+	}
 	updateTable(h.table, filteredJobs)
 }
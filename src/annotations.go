@@ -0,0 +1,258 @@
+package src
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Variable types understood by the configuration form
+const (
+	VarTypeString = "string"
+	VarTypeInt    = "int"
+	VarTypeFloat  = "float"
+	VarTypeBool   = "bool"
+	VarTypeEnum   = "enum"
+	// VarTypeText is a string edited in a multi-line text area, such as a shell script
+	VarTypeText = "text"
+)
+
+// annotationPattern matches a template comment of the form
+//
+//	# kstool: GPU_NUM type=int min=0 max=8 desc="Number of GPUs"
+var annotationPattern = regexp.MustCompile(`^\s*#\s*kstool:\s*([A-Za-z_][A-Za-z0-9_]*)\s*(.*)$`)
+
+// VarSpec describes how a template variable is edited and validated in the configuration form
+type VarSpec struct {
+	Name        string
+	Type        string
+	Options     []string
+	Min         *float64
+	Max         *float64
+	Pattern     *regexp.Regexp
+	Required    bool
+	Description string
+	// Secret variables are masked in the form, logs and history, see isSecretName
+	Secret bool
+
+	// OptionLabels optionally maps options to the text shown in the dropdown
+	OptionLabels map[string]string
+	// Source names the cluster resource listed as options, see sourcePVC and sourceQueue
+	Source string
+
+	// suggested marks options as suggestions only, as for the built-in specs
+	suggested bool
+}
+
+// builtinVarSpecs are used for well-known variables the template doesn't annotate
+var builtinVarSpecs = map[string]VarSpec{
+	"GPU_PRODUCT": {
+		Type:      VarTypeEnum,
+		Options:   []string{"NVIDIA-H200", "NVIDIA-H100-80GB-HBM3", "NVIDIA-A100-SXM4-80GB", "NVIDIA-A100-SXM4-40GB-MIG-3g.20gb"},
+		suggested: true,
+	},
+	"PRIORITY_CLASS": {
+		Type:      VarTypeEnum,
+		Options:   []string{"default-workload-priority", "batch-workload-priority", "short-workload-high-priority"},
+		suggested: true,
+	},
+}
+
+// parseVarSpecs collects the `# kstool:` annotations of a template.
+// Several annotation lines for the same variable are merged.
+func parseVarSpecs(content []byte) (map[string]*VarSpec, error) {
+	specs := make(map[string]*VarSpec)
+	for i, line := range strings.Split(string(content), "\n") {
+		matches := annotationPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		name := matches[1]
+		spec, exists := specs[name]
+		if !exists {
+			spec = &VarSpec{Name: name, Type: VarTypeString, Secret: isSecretName(name)}
+			specs[name] = spec
+		}
+
+		attrs, err := splitAnnotationAttrs(matches[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		for _, attr := range attrs {
+			if err := spec.setAttr(attr[0], attr[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %s: %v", i+1, name, err)
+			}
+		}
+	}
+	return specs, nil
+}
+
+// splitAnnotationAttrs splits `key=value key="quoted value" flag` into key/value pairs
+func splitAnnotationAttrs(text string) ([][2]string, error) {
+	var attrs [][2]string
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		end := strings.IndexAny(text, " \t=")
+		if end == -1 {
+			attrs = append(attrs, [2]string{text, ""})
+			break
+		}
+
+		key := text[:end]
+		if text[end] != '=' {
+			attrs = append(attrs, [2]string{key, ""})
+			text = text[end:]
+			continue
+		}
+
+		text = text[end+1:]
+		if strings.HasPrefix(text, `"`) {
+			closing := strings.Index(text[1:], `"`)
+			if closing == -1 {
+				return nil, fmt.Errorf("unterminated quote in %s", key)
+			}
+			attrs = append(attrs, [2]string{key, text[1 : closing+1]})
+			text = text[closing+2:]
+			continue
+		}
+
+		valueEnd := strings.IndexAny(text, " \t")
+		if valueEnd == -1 {
+			valueEnd = len(text)
+		}
+		attrs = append(attrs, [2]string{key, text[:valueEnd]})
+		text = text[valueEnd:]
+	}
+	return attrs, nil
+}
+
+// setAttr applies a single annotation attribute to the spec
+func (s *VarSpec) setAttr(key, value string) error {
+	switch key {
+	case "type":
+		switch value {
+		case VarTypeString, VarTypeInt, VarTypeFloat, VarTypeBool, VarTypeEnum, VarTypeText:
+			s.Type = value
+		case "multiline":
+			s.Type = VarTypeText
+		default:
+			return fmt.Errorf("unknown type %q", value)
+		}
+	case "options":
+		s.Options = strings.Split(value, ",")
+		if s.Type == VarTypeString {
+			s.Type = VarTypeEnum
+		}
+	case "min", "max":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", key, value)
+		}
+		if key == "min" {
+			s.Min = &number
+		} else {
+			s.Max = &number
+		}
+	case "pattern":
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+		s.Pattern = re
+	case "required":
+		s.Required = value == "" || value == "true"
+	case "desc":
+		s.Description = value
+	case "secret":
+		s.Secret = value == "" || value == "true"
+	case "source":
+		switch value {
+		case sourcePVC, sourceQueue:
+			s.Source = value
+		default:
+			return fmt.Errorf("unknown source %q", value)
+		}
+	default:
+		// Unknown attributes are ignored so newer templates still load
+	}
+	return nil
+}
+
+// varSpecFor returns the spec of a variable, falling back to built-in specs and plain strings
+func varSpecFor(specs map[string]*VarSpec, name string) *VarSpec {
+	if spec, exists := specs[name]; exists {
+		return spec
+	}
+	if builtin, exists := builtinVarSpecs[name]; exists {
+		spec := builtin
+		spec.Name = name
+		spec.Secret = isSecretName(name)
+		spec.Options = append([]string(nil), builtin.Options...)
+		return &spec
+	}
+	return &VarSpec{Name: name, Type: VarTypeString, Secret: isSecretName(name)}
+}
+
+// Validate checks a value against the spec
+func (s *VarSpec) Validate(value string) error {
+	if value == "" {
+		if s.Required {
+			return fmt.Errorf("%s is required", s.Name)
+		}
+		return nil
+	}
+
+	switch s.Type {
+	case VarTypeInt, VarTypeFloat:
+		var number float64
+		var err error
+		if s.Type == VarTypeInt {
+			var integer int64
+			integer, err = strconv.ParseInt(value, 10, 64)
+			number = float64(integer)
+		} else {
+			number, err = strconv.ParseFloat(value, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("%s must be a number of type %s", s.Name, s.Type)
+		}
+		if s.Min != nil && number < *s.Min {
+			return fmt.Errorf("%s must be at least %v", s.Name, *s.Min)
+		}
+		if s.Max != nil && number > *s.Max {
+			return fmt.Errorf("%s must be at most %v", s.Name, *s.Max)
+		}
+	case VarTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false", s.Name)
+		}
+	case VarTypeEnum:
+		found := false
+		for _, option := range s.Options {
+			if option == value {
+				found = true
+				break
+			}
+		}
+		if !found && len(s.Options) > 0 && !s.suggested {
+			return fmt.Errorf("%s must be one of %s", s.Name, strings.Join(s.Options, ", "))
+		}
+	}
+
+	if s.Pattern != nil && !s.Pattern.MatchString(value) {
+		return fmt.Errorf("%s does not match the expected format", s.Name)
+	}
+	return nil
+}
+
+// validateConfig validates every variable of the configuration against the template specs
+func validateConfig(config *Config, specs map[string]*VarSpec) []string {
+	var problems []string
+	for _, env := range config.EnvVars {
+		if err := varSpecFor(specs, env.Key).Validate(env.Value); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
@@ -0,0 +1,401 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+	"gopkg.in/yaml.v3"
+)
+
+// bundleFormatVersion is the version of the bundle file format written by exportBundle
+const bundleFormatVersion = 1
+
+// How importBundle handles configurations and templates that already exist
+const (
+	ConflictRename    = "rename"
+	ConflictOverwrite = "overwrite"
+	ConflictSkip      = "skip"
+)
+
+// ConflictPolicies lists the accepted conflict handling policies
+var ConflictPolicies = []string{ConflictRename, ConflictOverwrite, ConflictSkip}
+
+// Bundle is a set of saved configurations and the templates they depend on, shared as one file
+type Bundle struct {
+	Version    int              `yaml:"kstool_bundle"`
+	ExportedAt time.Time        `yaml:"exported_at"`
+	ExportedBy string           `yaml:"exported_by,omitempty"`
+	Configs    []BundleConfig   `yaml:"configs"`
+	Templates  []BundleTemplate `yaml:"templates"`
+}
+
+// BundleConfig is a saved configuration in a bundle
+type BundleConfig struct {
+	Name   string `yaml:"name"`
+	Config Config `yaml:"config"`
+}
+
+// BundleTemplate is a template in a bundle
+type BundleTemplate struct {
+	Name    string `yaml:"name"`
+	Content string `yaml:"content"`
+}
+
+// ImportResult describes what importBundle did
+type ImportResult struct {
+	Imported []string
+	// Renamed maps names in the bundle to the names they were imported as
+	Renamed     map[string]string
+	Overwritten []string
+	Skipped     []string
+}
+
+// Summary lists the outcome of an import one item per line
+func (r *ImportResult) Summary() string {
+	var lines []string
+	for _, name := range r.Imported {
+		if renamed, ok := r.Renamed[name]; ok {
+			lines = append(lines, fmt.Sprintf("imported %s as %s", name, renamed))
+		} else {
+			lines = append(lines, "imported "+name)
+		}
+	}
+	for _, name := range r.Overwritten {
+		lines = append(lines, "overwrote "+name)
+	}
+	for _, name := range r.Skipped {
+		lines = append(lines, "skipped "+name)
+	}
+	if len(lines) == 0 {
+		return "nothing to import"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validBundleName reports whether a configuration or template name is safe to use as a file name
+func validBundleName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// exportBundle writes the named saved configurations and their templates to a bundle file
+func exportBundle(names []string, path string) error {
+	user, _ := GetCurrentUser()
+	bundle := Bundle{
+		Version:    bundleFormatVersion,
+		ExportedAt: time.Now(),
+		ExportedBy: user,
+	}
+
+	templates := make(map[string]bool)
+	for _, name := range names {
+		config, err := loadConfig(name)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration %s: %v", name, err)
+		}
+
+		// Usage statistics are personal, and inherited values are exported so the parent isn't needed
+		config.LastAppliedAt = time.Time{}
+		config.ApplyCount = 0
+		config.Parent = ""
+		config.NoOverlay = false
+		// Secret values aren't shared, whoever imports the bundle enters their own
+		for name := range secretValues(*config) {
+			config.SetEnvVar(name, "")
+		}
+		bundle.Configs = append(bundle.Configs, BundleConfig{Name: name, Config: *config})
+
+		template := templateDisplayName(config.Template)
+		if templates[template] {
+			continue
+		}
+		templates[template] = true
+
+		content, err := readTemplate(template)
+		if err != nil {
+			return err
+		}
+		bundle.Templates = append(bundle.Templates, BundleTemplate{Name: template, Content: string(content)})
+	}
+
+	data, err := yaml.Marshal(&bundle)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write bundle: %v", err)
+	}
+	return nil
+}
+
+// readBundle reads and checks a bundle file
+func readBundle(path string) (*Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %v", err)
+	}
+
+	var bundle Bundle
+	if err := yaml.Unmarshal(data, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle: %v", err)
+	}
+	if bundle.Version == 0 {
+		return nil, fmt.Errorf("%s is not a KSTool bundle", path)
+	}
+	if bundle.Version > bundleFormatVersion {
+		return nil, fmt.Errorf("bundle format %d is newer than this KSTool supports", bundle.Version)
+	}
+
+	for _, config := range bundle.Configs {
+		if !validBundleName(config.Name) {
+			return nil, fmt.Errorf("invalid configuration name %q in bundle", config.Name)
+		}
+	}
+	for _, template := range bundle.Templates {
+		if !validBundleName(template.Name) {
+			return nil, fmt.Errorf("invalid template name %q in bundle", template.Name)
+		}
+	}
+	return &bundle, nil
+}
+
+// freeName returns name, or name with the first numeric suffix for which exists is false
+func freeName(name string, exists func(string) bool) string {
+	if !exists(name) {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !exists(candidate) {
+			return candidate
+		}
+	}
+}
+
+// sameConfigValues reports whether two configurations use the same template, values, overlay
+// and description
+func sameConfigValues(a, b *Config) bool {
+	if templateDisplayName(a.Template) != templateDisplayName(b.Template) || len(a.EnvVars) != len(b.EnvVars) {
+		return false
+	}
+	if a.Description != b.Description {
+		return false
+	}
+	if (len(a.Overlay) > 0 || len(b.Overlay) > 0) && !reflect.DeepEqual(a.Overlay, b.Overlay) {
+		return false
+	}
+	values := make(map[string]string)
+	for _, env := range a.EnvVars {
+		values[env.Key] = env.Value
+	}
+	for _, env := range b.EnvVars {
+		if value, ok := values[env.Key]; !ok || value != env.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// configExists reports whether a saved configuration exists
+func configExists(name string) bool {
+	_, err := loadConfig(name)
+	return err == nil
+}
+
+// templateExists reports whether a template is installed
+func templateExists(name string) bool {
+	path, err := templatePath(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// importBundle installs the configurations and templates of a bundle. Existing items are
+// renamed, overwritten or skipped according to policy; identical items are never duplicated.
+func importBundle(path, policy string) (*ImportResult, error) {
+	bundle, err := readBundle(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Renamed: make(map[string]string)}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %v", err)
+	}
+
+	// Templates first, so configurations can follow renamed templates
+	templateNames := make(map[string]string)
+	skippedTemplates := make(map[string]bool)
+	for _, template := range bundle.Templates {
+		name := template.Name
+		label := "template " + name
+		if existing, err := readTemplate(name); err == nil && templateExists(name) {
+			if string(existing) == template.Content {
+				templateNames[template.Name] = name
+				continue
+			}
+			switch policy {
+			case ConflictSkip:
+				result.Skipped = append(result.Skipped, label+" (already exists)")
+				skippedTemplates[template.Name] = true
+				continue
+			case ConflictOverwrite:
+				result.Overwritten = append(result.Overwritten, label)
+			default:
+				name = freeName(name, templateExists)
+				result.Renamed[label] = "template " + name
+				result.Imported = append(result.Imported, label)
+			}
+		} else {
+			result.Imported = append(result.Imported, label)
+		}
+
+		dest, err := templatePath(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create templates directory: %v", err)
+		}
+		if err := os.WriteFile(dest, []byte(template.Content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write template %s: %v", name, err)
+		}
+		templateNames[template.Name] = name
+	}
+
+	for _, entry := range bundle.Configs {
+		name := entry.Name
+		label := "config " + name
+		config := entry.Config
+
+		template := templateDisplayName(config.Template)
+		if skippedTemplates[template] {
+			// The local template differs, so the configuration may not match it
+			result.Skipped = append(result.Skipped, label+" (its template was skipped)")
+			continue
+		}
+		if renamed, ok := templateNames[template]; ok && renamed != template {
+			config.Template = renamed
+		}
+
+		if existing, err := loadConfig(name); err == nil {
+			if sameConfigValues(existing, &config) {
+				continue
+			}
+			switch policy {
+			case ConflictSkip:
+				result.Skipped = append(result.Skipped, label+" (already exists)")
+				continue
+			case ConflictOverwrite:
+				result.Overwritten = append(result.Overwritten, label)
+			default:
+				name = freeName(name, configExists)
+				result.Renamed[label] = "config " + name
+				result.Imported = append(result.Imported, label)
+			}
+		} else {
+			result.Imported = append(result.Imported, label)
+		}
+
+		config.CreatedAt = time.Now()
+		config.ModifiedAt = config.CreatedAt
+		if err := os.MkdirAll(filepath.Join(homeDir, configDir, configListDir), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create env_config_list directory: %v", err)
+		}
+		if err := writeConfig(name, &config); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// defaultBundlePath is offered as the bundle file in the export and import dialogs
+func defaultBundlePath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "kstool-bundle.yaml"
+	}
+	return filepath.Join(homeDir, "kstool-bundle.yaml")
+}
+
+// showExportDialog lets the user pick saved configurations and export them to a bundle
+func (f *CreateJobForm) showExportDialog(configs []string, selected string) {
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle("Export Configurations").SetTitleAlign(tview.AlignLeft)
+
+	checked := make(map[string]bool)
+	for _, name := range configs {
+		configName := name
+		checked[configName] = configName == selected
+		form.AddCheckbox(configName, checked[configName], func(value bool) {
+			checked[configName] = value
+		})
+	}
+	form.AddInputField("Bundle file", defaultBundlePath(), 50, nil, nil)
+
+	form.AddButton("Export", func() {
+		var names []string
+		for _, name := range configs {
+			if checked[name] {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			showError(f.app, form, "Select at least one configuration")
+			return
+		}
+
+		path := form.GetFormItemByLabel("Bundle file").(*tview.InputField).GetText()
+		if err := exportBundle(names, path); err != nil {
+			showError(f.app, form, fmt.Sprintf("Failed to export: %v", err))
+			return
+		}
+		showMessage(f.app, f.currentPanel, fmt.Sprintf("Exported %d configuration(s) to %s", len(names), path))
+	})
+	form.AddButton("Cancel", func() {
+		f.app.SetRoot(f.currentPanel, true)
+	})
+	form.SetCancelFunc(func() {
+		f.app.SetRoot(f.currentPanel, true)
+	})
+
+	f.app.SetRoot(form, true)
+}
+
+// showImportDialog imports a bundle with the chosen conflict handling
+func (f *CreateJobForm) showImportDialog() {
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle("Import Configurations").SetTitleAlign(tview.AlignLeft)
+
+	form.AddInputField("Bundle file", defaultBundlePath(), 50, nil, nil)
+	form.AddDropDown("If a configuration or template exists", ConflictPolicies, 0, nil)
+
+	form.AddButton("Import", func() {
+		path := form.GetFormItemByLabel("Bundle file").(*tview.InputField).GetText()
+		_, policy := form.GetFormItemByLabel("If a configuration or template exists").(*tview.DropDown).GetCurrentOption()
+
+		result, err := importBundle(path, policy)
+		if err != nil {
+			showError(f.app, form, fmt.Sprintf("Failed to import: %v", err))
+			return
+		}
+		f.showConfigList()
+		showMessage(f.app, f.currentPanel, "Import finished:\n\n"+result.Summary())
+	})
+	form.AddButton("Cancel", func() {
+		f.app.SetRoot(f.currentPanel, true)
+	})
+	form.SetCancelFunc(func() {
+		f.app.SetRoot(f.currentPanel, true)
+	})
+
+	f.app.SetRoot(form, true)
+}
@@ -0,0 +1,173 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const bundleTemplate = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: ${JOB_NAME:-train}
+`
+
+// writeTestTemplate installs a template in the temporary home
+func writeTestTemplate(t *testing.T, name, content string) {
+	t.Helper()
+	path, err := templatePath(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// exportTestBundle exports a configuration that inherits a secret and an overlay
+func exportTestBundle(t *testing.T) string {
+	t.Helper()
+	setupConfigs(t)
+	writeTestTemplate(t, "gpu", bundleTemplate)
+	overlay := map[string]interface{}{"spec": map[string]interface{}{"backoffLimit": 2}}
+	mustWriteConfig(t, "base", &Config{Template: "gpu", Overlay: overlay, EnvVars: []EnvVar{{Key: "HF_TOKEN", Value: "hf_abc"}}})
+	mustWriteConfig(t, "train", &Config{Parent: "base", EnvVars: []EnvVar{{Key: "JOB_NAME", Value: "train"}}})
+
+	path := filepath.Join(t.TempDir(), "bundle.yaml")
+	if err := exportBundle([]string{"train"}, path); err != nil {
+		t.Fatalf("exportBundle: %v", err)
+	}
+	return path
+}
+
+func TestExportBundle(t *testing.T) {
+	bundle, err := readBundle(exportTestBundle(t))
+	if err != nil {
+		t.Fatalf("readBundle: %v", err)
+	}
+	if len(bundle.Configs) != 1 || len(bundle.Templates) != 1 || bundle.Templates[0].Content != bundleTemplate {
+		t.Fatalf("unexpected bundle %+v", bundle)
+	}
+	config := bundle.Configs[0].Config
+	if config.Parent != "" || config.Overlay == nil {
+		t.Errorf("exported configuration has parent %q and overlay %v, want inherited values and no parent", config.Parent, config.Overlay)
+	}
+	if value, _ := config.GetEnvVar("HF_TOKEN"); value != "" {
+		t.Errorf("the secret value %q was exported", value)
+	}
+}
+
+func TestImportBundleConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		// change is made locally before importing
+		change func(t *testing.T)
+		want   ImportResult
+		// value is JOB_NAME of config train afterwards
+		value string
+	}{
+		{name: "identical configuration", policy: ConflictRename, want: ImportResult{Renamed: map[string]string{}}, value: "train"},
+		{
+			name:   "changed configuration renamed",
+			policy: ConflictRename,
+			change: func(t *testing.T) {
+				mustWriteConfig(t, "train", &Config{Template: "gpu", EnvVars: []EnvVar{{Key: "JOB_NAME", Value: "local"}}})
+			},
+			want: ImportResult{
+				Imported: []string{"config train"},
+				Renamed:  map[string]string{"config train": "config train-2"},
+			},
+			value: "local",
+		},
+		{
+			name:   "changed configuration overwritten",
+			policy: ConflictOverwrite,
+			change: func(t *testing.T) {
+				mustWriteConfig(t, "train", &Config{Template: "gpu", EnvVars: []EnvVar{{Key: "JOB_NAME", Value: "local"}}})
+			},
+			want:  ImportResult{Renamed: map[string]string{}, Overwritten: []string{"config train"}},
+			value: "train",
+		},
+		{
+			name:   "changed configuration skipped",
+			policy: ConflictSkip,
+			change: func(t *testing.T) {
+				mustWriteConfig(t, "train", &Config{Template: "gpu", EnvVars: []EnvVar{{Key: "JOB_NAME", Value: "local"}}})
+			},
+			want:  ImportResult{Renamed: map[string]string{}, Skipped: []string{"config train (already exists)"}},
+			value: "local",
+		},
+		{
+			name:   "changed template skipped",
+			policy: ConflictSkip,
+			change: func(t *testing.T) { writeTestTemplate(t, "gpu", bundleTemplate+"# local change\n") },
+			want: ImportResult{Renamed: map[string]string{}, Skipped: []string{
+				"template gpu (already exists)", "config train (its template was skipped)",
+			}},
+			value: "train",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := exportTestBundle(t)
+
+			// Import into a new home, then again after the local change
+			setupConfigs(t)
+			if _, err := importBundle(path, tt.policy); err != nil {
+				t.Fatalf("importBundle: %v", err)
+			}
+			if tt.change != nil {
+				tt.change(t)
+			}
+			result, err := importBundle(path, tt.policy)
+			if err != nil {
+				t.Fatalf("importBundle: %v", err)
+			}
+			if !reflect.DeepEqual(*result, tt.want) {
+				t.Errorf("import result = %+v, want %+v", *result, tt.want)
+			}
+			if value, _ := mustLoadConfig(t, "train").GetEnvVar("JOB_NAME"); value != tt.value {
+				t.Errorf("JOB_NAME = %q, want %q", value, tt.value)
+			}
+		})
+	}
+}
+
+func TestImportBundleRenamesTemplate(t *testing.T) {
+	path := exportTestBundle(t)
+	setupConfigs(t)
+	writeTestTemplate(t, "gpu", bundleTemplate+"# local change\n")
+
+	result, err := importBundle(path, ConflictRename)
+	if err != nil {
+		t.Fatalf("importBundle: %v", err)
+	}
+	if result.Renamed["template gpu"] != "template gpu-2" {
+		t.Errorf("template renames = %v, want gpu imported as gpu-2", result.Renamed)
+	}
+	if config := mustLoadConfig(t, "train"); config.Template != "gpu-2" {
+		t.Errorf("the imported configuration uses template %q, want gpu-2", config.Template)
+	}
+}
+
+func TestReadBundleRejectsUnsafeNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.yaml")
+	for _, content := range []string{
+		"configs: []\n",
+		"kstool_bundle: 1\nconfigs:\n  - name: ../evil\n",
+		"kstool_bundle: 1\ntemplates:\n  - name: a/b\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readBundle(path); err == nil {
+			t.Errorf("readBundle accepted %q", content)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package src
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// RunCommand runs a command-line subcommand and returns the process exit code
+func RunCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+
+	switch args[0] {
+	case "lint":
+		return runLint(args[1:], stdout, stderr)
+	case "export":
+		return runExport(args[1:], stdout, stderr)
+	case "import":
+		return runImport(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		printUsage(stdout)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+}
+
+// printUsage prints the available subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, `Usage: kstool [command]
+
+Without a command KSTool starts the interactive job browser.
+
+Commands:
+  lint <config|file>...   Check saved configurations or manifest/template files for common mistakes
+  export -o <file> [config]...
+                          Export saved configurations (all by default) and their templates to a bundle
+  import [-on-conflict rename|overwrite|skip] <file>
+                          Import the configurations and templates of a bundle
+  help                    Show this help`)
+}
+
+// runLint lints saved configurations by name or manifest and template files by path.
+// It exits with 1 when any lint error is found.
+func runLint(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: kstool lint <config|file>...")
+		return 2
+	}
+
+	exitCode := 0
+	for _, arg := range args {
+		issues, err := lintTarget(arg)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", arg, err)
+			exitCode = 1
+			continue
+		}
+
+		if len(issues) == 0 {
+			fmt.Fprintf(stdout, "%s: ok\n", arg)
+			continue
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(stdout, "%s: %s\n", arg, issue)
+		}
+		if hasLintErrors(issues) {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// lintTarget lints a file if the argument names one, otherwise the saved configuration of that name.
+// Files are rendered first so templates are checked with their default values.
+func lintTarget(target string) ([]LintIssue, error) {
+	if content, err := os.ReadFile(target); err == nil {
+		manifest, err := renderTemplate(content, Config{})
+		if err != nil {
+			return nil, err
+		}
+		return LintManifest(manifest)
+	}
+
+	config, err := loadConfig(target)
+	if err != nil {
+		return nil, fmt.Errorf("not a file or saved configuration: %v", err)
+	}
+	return lintConfig(*config)
+}
+
+// runExport writes saved configurations and their templates to a bundle file
+func runExport(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "bundle file to write")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *output == "" {
+		fmt.Fprintln(stderr, "usage: kstool export -o <file> [config]...")
+		return 2
+	}
+
+	names := flags.Args()
+	if len(names) == 0 {
+		configs, err := loadConfigList()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		names = configs
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(stderr, "no saved configurations to export")
+		return 1
+	}
+
+	if err := exportBundle(names, *output); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "exported %s to %s\n", strings.Join(names, ", "), *output)
+	return 0
+}
+
+// runImport installs the configurations and templates of a bundle file
+func runImport(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	policy := flags.String("on-conflict", ConflictRename, "what to do with existing configurations and templates: "+strings.Join(ConflictPolicies, ", "))
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: kstool import [-on-conflict rename|overwrite|skip] <file>")
+		return 2
+	}
+
+	valid := false
+	for _, p := range ConflictPolicies {
+		valid = valid || p == *policy
+	}
+	if !valid {
+		fmt.Fprintf(stderr, "invalid conflict policy %q, use one of: %s\n", *policy, strings.Join(ConflictPolicies, ", "))
+		return 2
+	}
+
+	if err := initializeDirectories(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	result, err := importBundle(flags.Arg(0), *policy)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintln(stdout, result.Summary())
+	return 0
+}
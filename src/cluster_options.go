@@ -0,0 +1,375 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// gpuProductLabel is the node label holding the GPU product name
+	gpuProductLabel = "nvidia.com/gpu.product"
+	gpuResource     = corev1.ResourceName("nvidia.com/gpu")
+
+	gpuProductCacheFile = "gpu_products_cache.yaml"
+
+	// clusterQueryTimeout bounds how long the form waits for cluster data
+	clusterQueryTimeout = 3 * time.Second
+
+	// Cluster resources a variable can be bound to
+	sourcePVC   = "pvc"
+	sourceQueue = "queue"
+
+	kueueQueueLabel = "kueue.x-k8s.io/queue-name"
+)
+
+// localQueueResource is the Kueue LocalQueue resource
+var localQueueResource = schema.GroupVersionResource{Group: "kueue.x-k8s.io", Version: "v1beta1", Resource: "localqueues"}
+
+// GPUProduct summarises the GPUs of one product across the cluster
+type GPUProduct struct {
+	Name  string
+	Total int64
+	// Free is -1 when pod usage couldn't be read
+	Free int64
+}
+
+// gpuProductCache is the last GPU product list read from the cluster
+type gpuProductCache struct {
+	Products []string  `yaml:"products"`
+	Updated  time.Time `yaml:"updated"`
+}
+
+// fetchGPUProducts lists the GPU products of the cluster nodes with their free GPU counts
+func fetchGPUProducts(ctx context.Context, clientset kubernetes.Interface) ([]GPUProduct, error) {
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: gpuProductLabel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+
+	products := make(map[string]*GPUProduct)
+	nodeProducts := make(map[string]string)
+	for _, node := range nodes.Items {
+		name := node.Labels[gpuProductLabel]
+		if name == "" {
+			continue
+		}
+		product, exists := products[name]
+		if !exists {
+			product = &GPUProduct{Name: name}
+			products[name] = product
+		}
+		allocatable := node.Status.Allocatable[gpuResource]
+		product.Total += allocatable.Value()
+		nodeProducts[node.Name] = name
+	}
+
+	if len(products) == 0 {
+		return nil, fmt.Errorf("no nodes carry the %s label", gpuProductLabel)
+	}
+
+	// Subtract GPUs used by running pods; users without cluster-wide pod access only see totals
+	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err == nil {
+		used := make(map[string]int64)
+		for _, pod := range pods.Items {
+			if name, exists := nodeProducts[pod.Spec.NodeName]; exists {
+				used[name] += podGPURequests(&pod)
+			}
+		}
+		for name, product := range products {
+			product.Free = product.Total - used[name]
+		}
+	} else {
+		for _, product := range products {
+			product.Free = -1
+		}
+	}
+
+	result := make([]GPUProduct, 0, len(products))
+	for _, product := range products {
+		result = append(result, *product)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// podGPURequests returns the number of GPUs requested by the containers of a pod
+func podGPURequests(pod *corev1.Pod) int64 {
+	var total int64
+	for _, container := range pod.Spec.Containers {
+		if limit, exists := container.Resources.Limits[gpuResource]; exists {
+			total += limit.Value()
+		} else if request, exists := container.Resources.Requests[gpuResource]; exists {
+			total += request.Value()
+		}
+	}
+	return total
+}
+
+// gpuProductCachePath returns the path of the GPU product cache file
+func gpuProductCachePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, configDir, gpuProductCacheFile), nil
+}
+
+// saveGPUProductCache remembers the GPU products for when the cluster is unreachable
+func saveGPUProductCache(products []GPUProduct) error {
+	path, err := gpuProductCachePath()
+	if err != nil {
+		return err
+	}
+
+	cache := gpuProductCache{Updated: time.Now()}
+	for _, product := range products {
+		cache.Products = append(cache.Products, product.Name)
+	}
+
+	data, err := yaml.Marshal(cache)
+	if err != nil {
+		return fmt.Errorf("failed to marshal GPU product cache: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write GPU product cache: %v", err)
+	}
+	return nil
+}
+
+// loadGPUProductCache reads the cached GPU products
+func loadGPUProductCache() (*gpuProductCache, error) {
+	path, err := gpuProductCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cache gpuProductCache
+	if err := yaml.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse GPU product cache: %v", err)
+	}
+	return &cache, nil
+}
+
+// gpuProductSpec builds the GPU_PRODUCT dropdown from the cluster, falling back to
+// the cached list, the configured list and finally the built-in options
+func gpuProductSpec(ctx context.Context, clients *KubeClients, spec *VarSpec) {
+	if clients != nil {
+		queryCtx, cancel := context.WithTimeout(ctx, clusterQueryTimeout)
+		products, err := fetchGPUProducts(queryCtx, clients.Clientset)
+		cancel()
+		if err == nil {
+			spec.Options = nil
+			spec.OptionLabels = make(map[string]string)
+			for _, product := range products {
+				spec.Options = append(spec.Options, product.Name)
+				if product.Free >= 0 {
+					spec.OptionLabels[product.Name] = fmt.Sprintf("%s (%d/%d free)", product.Name, product.Free, product.Total)
+				} else {
+					spec.OptionLabels[product.Name] = fmt.Sprintf("%s (%d total)", product.Name, product.Total)
+				}
+			}
+			describeOptionSource(spec, "GPU products available on the cluster")
+			saveGPUProductCache(products)
+			return
+		}
+	}
+
+	if cache, err := loadGPUProductCache(); err == nil && len(cache.Products) > 0 {
+		spec.Options = cache.Products
+		describeOptionSource(spec, fmt.Sprintf("cluster unreachable, GPU products cached at %s", cache.Updated.Format("2006-01-02 15:04")))
+		return
+	}
+
+	if settings, err := loadSettings(); err == nil && len(settings.GPUProducts) > 0 {
+		spec.Options = settings.GPUProducts
+		describeOptionSource(spec, "cluster unreachable, GPU products from settings.yaml")
+		return
+	}
+
+	if len(spec.Options) == 0 {
+		spec.Options = append([]string(nil), builtinVarSpecs["GPU_PRODUCT"].Options...)
+	}
+}
+
+// describeOptionSource adds where the dropdown options came from to the field help
+func describeOptionSource(spec *VarSpec, source string) {
+	if spec.Description == "" {
+		spec.Description = source
+	} else {
+		spec.Description = fmt.Sprintf("%s (%s)", spec.Description, source)
+	}
+}
+
+// variableSources infers which variables name cluster resources from where they are used in the template:
+// values of persistentVolumeClaim.claimName are PVCs and the kueue.x-k8s.io/queue-name label is a LocalQueue
+func variableSources(content []byte) (map[string]string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+
+	sources := make(map[string]string)
+	declared := declaredVariables(content)
+	var walk func(node *yaml.Node, path []string)
+	walk = func(node *yaml.Node, path []string) {
+		switch node.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				walk(node.Content[i+1], append(path, node.Content[i].Value))
+			}
+		case yaml.ScalarNode:
+			source := ""
+			switch {
+			case strings.HasSuffix(strings.Join(path, "."), "persistentVolumeClaim.claimName"):
+				source = sourcePVC
+			case len(path) > 0 && path[len(path)-1] == kueueQueueLabel:
+				source = sourceQueue
+			}
+			if source == "" {
+				return
+			}
+			if parsed, err := parseTemplate([]byte(node.Value), declared); err == nil {
+				for _, placeholder := range parsed.Placeholders {
+					sources[placeholder.Name] = source
+				}
+			}
+		}
+	}
+	walk(&root, nil)
+	return sources, nil
+}
+
+// fetchPVCOptions lists the PersistentVolumeClaims of the namespace with their size and status
+func fetchPVCOptions(ctx context.Context, clients *KubeClients) ([]string, map[string]string, error) {
+	pvcs, err := clients.Clientset.CoreV1().PersistentVolumeClaims(clients.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list PVCs: %v", err)
+	}
+
+	var options []string
+	labels := make(map[string]string)
+	for _, pvc := range pvcs.Items {
+		size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if capacity, exists := pvc.Status.Capacity[corev1.ResourceStorage]; exists {
+			size = capacity
+		}
+		options = append(options, pvc.Name)
+		labels[pvc.Name] = fmt.Sprintf("%s (%s, %s)", pvc.Name, size.String(), pvc.Status.Phase)
+	}
+	sort.Strings(options)
+	return options, labels, nil
+}
+
+// fetchQueueOptions lists the Kueue LocalQueues of the namespace with their ClusterQueue and backlog
+func fetchQueueOptions(ctx context.Context, clients *KubeClients) ([]string, map[string]string, error) {
+	queues, err := clients.Dynamic.Resource(localQueueResource).Namespace(clients.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list LocalQueues: %v", err)
+	}
+
+	var options []string
+	labels := make(map[string]string)
+	for _, queue := range queues.Items {
+		clusterQueue, _, _ := unstructured.NestedString(queue.Object, "spec", "clusterQueue")
+		pending, _, _ := unstructured.NestedInt64(queue.Object, "status", "pendingWorkloads")
+		admitted, _, _ := unstructured.NestedInt64(queue.Object, "status", "admittedWorkloads")
+		options = append(options, queue.GetName())
+		labels[queue.GetName()] = fmt.Sprintf("%s (%s, %d admitted, %d pending)", queue.GetName(), clusterQueue, admitted, pending)
+	}
+	sort.Strings(options)
+	return options, labels, nil
+}
+
+// applyClusterOptions fills the options of cluster-backed variables the template doesn't annotate
+func (f *CreateJobForm) applyClusterOptions(specs map[string]*VarSpec, config *Config, content []byte) {
+	if _, exists := config.GetEnvVar("GPU_PRODUCT"); exists {
+		if annotated, exists := specs["GPU_PRODUCT"]; !exists || len(annotated.Options) == 0 {
+			spec := varSpecFor(specs, "GPU_PRODUCT")
+			spec.Type = VarTypeEnum
+			spec.suggested = true
+			gpuProductSpec(f.ctx, f.clients, spec)
+			specs["GPU_PRODUCT"] = spec
+		}
+	}
+
+	if f.clients == nil {
+		return
+	}
+
+	// Variables explicitly annotated with a source win over inferred ones. Templates that
+	// aren't valid YAML before rendering only get the annotated sources.
+	sources, err := variableSources(content)
+	if err != nil {
+		sources = make(map[string]string)
+	}
+	for name, spec := range specs {
+		if spec.Source != "" {
+			sources[name] = spec.Source
+		}
+	}
+
+	// Each resource kind is listed at most once; unreachable resources leave plain input fields
+	fetched := make(map[string]bool)
+	options := make(map[string][]string)
+	optionLabels := make(map[string]map[string]string)
+	for name, source := range sources {
+		if _, exists := config.GetEnvVar(name); !exists {
+			continue
+		}
+		if annotated, exists := specs[name]; exists && len(annotated.Options) > 0 {
+			continue
+		}
+
+		if !fetched[source] {
+			fetched[source] = true
+			queryCtx, cancel := context.WithTimeout(f.ctx, clusterQueryTimeout)
+			var err error
+			switch source {
+			case sourcePVC:
+				options[source], optionLabels[source], err = fetchPVCOptions(queryCtx, f.clients)
+			case sourceQueue:
+				options[source], optionLabels[source], err = fetchQueueOptions(queryCtx, f.clients)
+			}
+			cancel()
+			if err != nil {
+				delete(options, source)
+			}
+		}
+		if len(options[source]) == 0 {
+			continue
+		}
+
+		spec := varSpecFor(specs, name)
+		spec.Type = VarTypeEnum
+		spec.Options = options[source]
+		spec.OptionLabels = optionLabels[source]
+		spec.suggested = true
+		specs[name] = spec
+	}
+}
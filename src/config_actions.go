@@ -0,0 +1,128 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// configFilePath returns the path of a saved configuration file
+func configFilePath(name string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, configDir, configListDir, name+".yaml"), nil
+}
+
+// configFileExists reports whether a configuration file of that name exists, even one that doesn't load
+func configFileExists(name string) bool {
+	path, err := configFilePath(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// checkNewConfigName reports an error when a configuration can't be created under name
+func checkNewConfigName(name string) error {
+	if !validBundleName(name) {
+		return fmt.Errorf("invalid configuration name %q", name)
+	}
+	if configFileExists(name) {
+		return fmt.Errorf("a configuration named %s already exists", name)
+	}
+	return nil
+}
+
+// renameConfig renames a saved configuration and updates the configurations inheriting from it
+// and its drafts
+func renameConfig(oldName, newName string) error {
+	if oldName == newName {
+		return nil
+	}
+	if err := checkNewConfigName(newName); err != nil {
+		return err
+	}
+
+	children, err := configChildren(oldName)
+	if err != nil {
+		return err
+	}
+
+	oldPath, err := configFilePath(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := configFilePath(newName)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("failed to rename config file: %v", err)
+	}
+
+	for _, child := range children {
+		config, err := readConfigFile(child)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %v", child, err)
+		}
+		config.Parent = newName
+		if err := writeConfig(child, config); err != nil {
+			return fmt.Errorf("failed to update %s: %v", child, err)
+		}
+	}
+
+	if err := renameDrafts(oldName, newName); err != nil {
+		return fmt.Errorf("failed to move the drafts of %s: %v", oldName, err)
+	}
+	if err := renamePipelineConfig(oldName, newName); err != nil {
+		return fmt.Errorf("failed to update the pipelines using %s: %v", oldName, err)
+	}
+	return nil
+}
+
+// duplicateConfig saves a copy of a configuration under a new name, with fresh usage statistics.
+// The copy inherits from the same parent as the original.
+func duplicateConfig(name, newName string) error {
+	if err := checkNewConfigName(newName); err != nil {
+		return err
+	}
+
+	config, err := readConfigFile(name)
+	if err != nil {
+		return err
+	}
+	updateConfigMetadata(newName, config)
+	return writeConfig(newName, config)
+}
+
+// showConfigNameDialog asks for the name of a configuration. apply is called with the entered
+// name; the configuration list is shown again when it succeeds.
+func (f *CreateJobForm) showConfigNameDialog(title, initial string, apply func(name string) error) {
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
+	form.AddInputField("Name", initial, 40, nil, nil)
+
+	submit := func() {
+		name := strings.TrimSpace(form.GetFormItemByLabel("Name").(*tview.InputField).GetText())
+		if err := apply(name); err != nil {
+			showError(f.app, form, err.Error())
+			return
+		}
+		f.showConfigList()
+	}
+	form.AddButton("OK", submit)
+	form.AddButton("Cancel", func() {
+		f.app.SetRoot(f.currentPanel, true)
+	})
+	form.SetCancelFunc(func() {
+		f.app.SetRoot(f.currentPanel, true)
+	})
+
+	f.app.SetRoot(form, true)
+}
@@ -0,0 +1,116 @@
+package src
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// templateFingerprint identifies the current content of a template
+func templateFingerprint(template string) string {
+	content, err := readTemplate(template)
+	if err != nil {
+		return ""
+	}
+	return templateVersion(content)
+}
+
+// updateConfigMetadata sets the timestamps and template fingerprint of a configuration about
+// to be saved as name. Usage statistics are kept when an existing configuration is overwritten.
+func updateConfigMetadata(name string, config *Config) {
+	now := time.Now()
+	if existing, err := loadConfig(name); err == nil {
+		config.CreatedAt = existing.CreatedAt
+		config.LastAppliedAt = existing.LastAppliedAt
+		config.ApplyCount = existing.ApplyCount
+	} else {
+		// A new configuration, possibly copied from another one
+		config.CreatedAt = now
+		config.LastAppliedAt = time.Time{}
+		config.ApplyCount = 0
+	}
+	config.ModifiedAt = now
+	config.TemplateFingerprint = templateFingerprint(config.Template)
+}
+
+// recordConfigApplied updates the usage statistics of a saved configuration after a job was created from it
+func recordConfigApplied(name string) error {
+	config, err := readConfigFile(name)
+	if err != nil {
+		return err
+	}
+
+	config.LastAppliedAt = time.Now()
+	config.ApplyCount++
+	return writeConfig(name, config)
+}
+
+// lastUsed returns when a configuration was last applied or, failing that, modified
+func (c *Config) lastUsed() time.Time {
+	if c.LastAppliedAt.After(c.ModifiedAt) {
+		return c.LastAppliedAt
+	}
+	return c.ModifiedAt
+}
+
+// sortConfigsByRecentUse orders configuration names by most recent use, most recent first
+func sortConfigsByRecentUse(names []string, configs map[string]*Config) {
+	sort.SliceStable(names, func(i, j int) bool {
+		var ti, tj time.Time
+		if config := configs[names[i]]; config != nil {
+			ti = config.lastUsed()
+		}
+		if config := configs[names[j]]; config != nil {
+			tj = config.lastUsed()
+		}
+		return ti.After(tj)
+	})
+}
+
+// formatTimeAgo describes how long ago t was
+func formatTimeAgo(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+	return t.Format("2006-01-02")
+}
+
+// describeConfig summarizes a saved configuration for the configuration list
+func describeConfig(config *Config) string {
+	var parts []string
+	if config.Description != "" {
+		parts = append(parts, config.Description)
+	}
+	if config.Parent != "" {
+		parts = append(parts, "Inherits from "+config.Parent)
+	}
+
+	template := "Template: " + templateDisplayName(config.Template)
+	if config.TemplateFingerprint != "" && config.TemplateFingerprint != templateFingerprint(config.Template) {
+		template += " (changed since saved)"
+	}
+	parts = append(parts, template)
+
+	switch config.ApplyCount {
+	case 0:
+		parts = append(parts, "Never applied")
+	case 1:
+		parts = append(parts, "Applied once, "+formatTimeAgo(config.LastAppliedAt))
+	default:
+		parts = append(parts, fmt.Sprintf("Applied %d times, last %s", config.ApplyCount, formatTimeAgo(config.LastAppliedAt)))
+	}
+
+	if !config.ModifiedAt.IsZero() {
+		parts = append(parts, "Modified "+formatTimeAgo(config.ModifiedAt))
+	}
+	return strings.Join(parts, " | ")
+}
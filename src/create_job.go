@@ -3,18 +3,15 @@ package src
 import (
 	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/suchun/kstool/config"
 	"gopkg.in/yaml.v3"
 )
 
@@ -32,6 +29,23 @@ type EnvVar struct {
 
 // Config represents the configuration for a job
 type Config struct {
+	Template    string `yaml:"template,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// Parent names a saved configuration whose values are used for variables not set here
+	Parent string `yaml:"parent,omitempty"`
+
+	CreatedAt     time.Time `yaml:"created_at,omitempty"`
+	ModifiedAt    time.Time `yaml:"modified_at,omitempty"`
+	LastAppliedAt time.Time `yaml:"last_applied_at,omitempty"`
+	ApplyCount    int       `yaml:"apply_count,omitempty"`
+	// TemplateFingerprint identifies the template content the configuration was saved against
+	TemplateFingerprint string `yaml:"template_fingerprint,omitempty"`
+	// Overlay is a strategic merge patch applied to the rendered Job, see applyOverlay
+	Overlay map[string]interface{} `yaml:"overlay,omitempty"`
+	// NoOverlay marks a configuration whose overlay was removed with Remove Overlay, so it
+	// doesn't inherit the overlay of its parent
+	NoOverlay bool `yaml:"no_overlay,omitempty"`
+
 	EnvVars []EnvVar `yaml:"env_vars"`
 }
 
@@ -59,12 +73,32 @@ func (c *Config) SetEnvVar(key, value string) {
 // CreateJobForm represents the form for creating a new job
 type CreateJobForm struct {
 	app          *tview.Application
+	ctx          context.Context
+	clients      *KubeClients
 	form         *tview.Form
 	config       *Config
 	onClose      func()
-	configList   *tview.List
+	configList   tview.Primitive
 	flex         *tview.Flex
 	currentPanel tview.Primitive
+	// pendingUpdate is a base template update waiting to be offered
+	pendingUpdate *templateUpdate
+	// templateSource tells where the base template came from when the form was opened
+	templateSource string
+	// configName is the saved configuration being edited, empty for a new one
+	configName string
+	// sortByRecentUse orders the configuration list by last use instead of by name
+	sortByRecentUse bool
+	// hidePreview hides the rendered manifest next to the configuration form
+	hidePreview bool
+	// draft is the autosaved copy of the configuration form being edited
+	draft *configDraft
+	// restoredDraft is the draft the next configuration form is opened from
+	restoredDraft *configDraft
+	// draftPrompt is the question about restoring a draft while it is shown
+	draftPrompt tview.Primitive
+	// draftsOffered is set once the user chose to restore drafts later
+	draftsOffered bool
 }
 
 // initializeDirectories ensures all required directories exist
@@ -86,55 +120,78 @@ func initializeDirectories() error {
 		return fmt.Errorf("failed to create env_config_list directory: %v", err)
 	}
 
-	return nil
-}
+	// Create templates directory
+	if err := os.MkdirAll(filepath.Join(kstoolDir, templatesDir), 0755); err != nil {
+		return fmt.Errorf("failed to create templates directory: %v", err)
+	}
 
-// downloadBaseConfig downloads the base configuration file if it doesn't exist
-func downloadBaseConfig() error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %v", err)
+	// Create history directory
+	if err := os.MkdirAll(filepath.Join(kstoolDir, historyDir), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %v", err)
 	}
 
-	baseConfigPath := filepath.Join(homeDir, configDir, "base_apply.yaml")
-	templatePath := filepath.Join(homeDir, configDir, "base_apply_template.yaml")
+	// Create drafts directory
+	if err := os.MkdirAll(filepath.Join(kstoolDir, draftsDir), 0755); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %v", err)
+	}
 
-	// Download base config if it doesn't exist
-	if _, err := os.Stat(baseConfigPath); os.IsNotExist(err) {
-		// Download the file
-		resp, err := http.Get(baseConfigURL)
-		if err != nil {
-			return fmt.Errorf("failed to download base config: %v", err)
-		}
-		defer resp.Body.Close()
+	// Create pipelines directory
+	if err := os.MkdirAll(filepath.Join(kstoolDir, pipelinesDir, pipelineRunsDir), 0755); err != nil {
+		return fmt.Errorf("failed to create pipelines directory: %v", err)
+	}
 
-		// Read the content
-		content, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read response body: %v", err)
-		}
+	return nil
+}
 
-		// Save the original base config
-		if err := os.WriteFile(baseConfigPath, content, 0644); err != nil {
-			return fmt.Errorf("failed to write base config file: %v", err)
-		}
+// Sources of the base template
+const (
+	templateSourceLocal      = "local"
+	templateSourceDownloaded = "downloaded"
+	templateSourceEmbedded   = "embedded"
+)
+
+// describeTemplateSource returns a title suffix telling where a newly installed base template came from
+func describeTemplateSource(source string) string {
+	switch source {
+	case templateSourceDownloaded:
+		return " (base template downloaded from GitHub)"
+	case templateSourceEmbedded:
+		return " (built-in base template, offline)"
 	}
+	return ""
+}
 
-	// Always read the base config and generate template
-	content, err := os.ReadFile(baseConfigPath)
+// downloadBaseConfig downloads the base configuration file if it doesn't exist and records
+// its upstream version so later updates can be merged with local edits. When GitHub can't be
+// reached, the template embedded in the binary is installed instead. It returns where the
+// base template came from.
+func downloadBaseConfig() (string, error) {
+	localPath, _, _, err := templateSyncPaths()
 	if err != nil {
-		return fmt.Errorf("failed to read base config: %v", err)
+		return "", err
 	}
 
-	// Create template file with $VAR_NAME format
-	re := regexp.MustCompile(`\${([^:}]+):-[^}]+}`)
-	processedContent := re.ReplaceAllString(string(content), "$$$1")
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		return templateSourceLocal, nil
+	}
 
-	if err := os.WriteFile(templatePath, []byte(processedContent), 0644); err != nil {
-		return fmt.Errorf("failed to write template file: %v", err)
+	state := &templateSyncState{}
+	source := templateSourceDownloaded
+	content, etag, _, err := baseTemplateUpstream.fetch("")
+	if err != nil {
+		// Offline, the next update check compares the embedded copy with upstream
+		content, etag = config.BaseTemplate, ""
+		source = templateSourceEmbedded
+	} else {
+		state.CheckedAt = time.Now()
 	}
 
-	return nil
+	// Save the original base config
+	if err := os.WriteFile(localPath, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write base config file: %v", err)
+	}
+
+	return source, recordUpstreamTemplate(state, content, etag)
 }
 
 // loadConfigList loads all configuration files from the env_config_list directory
@@ -162,8 +219,13 @@ func loadConfigList() ([]string, error) {
 	return configs, nil
 }
 
-// loadConfig loads a specific configuration file
+// loadConfig loads a specific configuration, resolving the configurations it inherits from
 func loadConfig(name string) (*Config, error) {
+	return resolveConfig(name, nil)
+}
+
+// readConfigFile reads a configuration file as saved, without resolving its parent
+func readConfigFile(name string) (*Config, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %v", err)
@@ -180,108 +242,34 @@ func loadConfig(name string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
 
-	return &config, nil
-}
-
-// extractEnvVars extracts environment variables and their default values from YAML content
-func extractEnvVars(yamlContent []byte) (map[string]string, error) {
-	var data map[string]interface{}
-	if err := yaml.Unmarshal(yamlContent, &data); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %v", err)
-	}
-
-	envVars := make(map[string]string)
-	currentUser := os.Getenv("USER")
-
-	// Function to recursively search for environment variables and their default values
-	var searchEnvVars func(interface{})
-	searchEnvVars = func(value interface{}) {
-		switch v := value.(type) {
-		case string:
-			// Match pattern ${VAR_NAME:-default_value}
-			if matches := regexp.MustCompile(`\${([^:}]+):-([^}]+)}`).FindStringSubmatch(v); len(matches) > 2 {
-				envVar := matches[1]
-				defaultValue := matches[2]
-
-				// Replace "default-user" with current username in default value
-				if strings.Contains(defaultValue, "default-user") {
-					defaultValue = strings.ReplaceAll(defaultValue, "default-user", currentUser)
-				}
-
-				if _, exists := envVars[envVar]; !exists {
-					envVars[envVar] = defaultValue
-				}
-			}
-		case map[string]interface{}:
-			for _, val := range v {
-				searchEnvVars(val)
-			}
-		case []interface{}:
-			for _, item := range v {
-				searchEnvVars(item)
-			}
+	// Files saved before timestamps were recorded fall back to the file's modification time
+	if config.ModifiedAt.IsZero() {
+		if info, err := os.Stat(configPath); err == nil {
+			config.ModifiedAt = info.ModTime()
 		}
 	}
 
-	searchEnvVars(data)
-	return envVars, nil
+	return &config, nil
 }
 
-// loadBaseConfig loads the base configuration and extracts environment variables with their default values
-func loadBaseConfig() (*Config, error) {
-	homeDir, err := os.UserHomeDir()
+// loadBaseConfig loads a template and extracts its variables with their default values
+func loadBaseConfig(template string) (*Config, error) {
+	data, err := readTemplate(template)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %v", err)
+		return nil, err
 	}
 
-	baseConfigPath := filepath.Join(homeDir, configDir, "base_apply.yaml")
-	data, err := os.ReadFile(baseConfigPath)
+	parsed, err := ParseTemplate(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read base config: %v", err)
-	}
-
-	// Extract environment variables from the YAML content
-	var yamlData map[string]interface{}
-	if err := yaml.Unmarshal(data, &yamlData); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %v", err)
+		return nil, fmt.Errorf("failed to parse template: %v", err)
 	}
 
-	config := &Config{}
-	currentUser := os.Getenv("USER")
-
-	// Function to recursively search for environment variables and their default values
-	var searchEnvVars func(interface{})
-	searchEnvVars = func(value interface{}) {
-		switch v := value.(type) {
-		case string:
-			// Match pattern ${VAR_NAME:-default_value}
-			if matches := regexp.MustCompile(`\${([^:}]+):-([^}]+)}`).FindStringSubmatch(v); len(matches) > 2 {
-				envVar := matches[1]
-				defaultValue := matches[2]
-
-				// Replace "default-user" with current username in default value
-				if strings.Contains(defaultValue, "default-user") {
-					defaultValue = strings.ReplaceAll(defaultValue, "default-user", currentUser)
-				}
-
-				// Only add if not already present
-				if _, exists := config.GetEnvVar(envVar); !exists {
-					config.EnvVars = append(config.EnvVars, EnvVar{Key: envVar, Value: defaultValue})
-				}
-			}
-		case map[string]interface{}:
-			for _, val := range v {
-				searchEnvVars(val)
-			}
-		case []interface{}:
-			for _, item := range v {
-				searchEnvVars(item)
-			}
-		}
+	config := &Config{Template: template}
+	for _, variable := range parsed.Variables() {
+		// Replace "default-user" with current username in default value
+		config.EnvVars = append(config.EnvVars, EnvVar{Key: variable.Name, Value: personalizeDefault(variable.Default)})
 	}
 
-	searchEnvVars(yamlData)
-
 	// Sort environment variables by key
 	sort.Slice(config.EnvVars, func(i, j int) bool {
 		return config.EnvVars[i].Key < config.EnvVars[j].Key
@@ -296,53 +284,259 @@ func (f *CreateJobForm) createConfigForm(config *Config) tview.Primitive {
 	form := tview.NewForm()
 	form.SetBorder(true).SetTitle("Job Configuration").SetTitleAlign(tview.AlignLeft)
 
-	// Track if the form has been modified
-	modified := false
-
-	// Add form fields for each environment variable
-	for _, env := range config.EnvVars {
-		// Create new variables for the closure
-		key := env.Key
-		value := env.Value
-
-		// Special handling for GPU product dropdown
-		if key == "GPU_PRODUCT" {
-			gpuOptions := []string{"NVIDIA-H200", "NVIDIA-H100-80GB-HBM3", "NVIDIA-A100-SXM4-80GB", "NVIDIA-A100-SXM4-40GB-MIG-3g.20gb"}
-			defaultIndex := 0
-			for i, option := range gpuOptions {
-				if option == value {
-					defaultIndex = i
-					break
-				}
+	// Problems found while building the form, shown once it is on screen
+	var warnings []string
+
+	// Load the variable annotations of the template
+	specs := map[string]*VarSpec{}
+	content, err := readTemplate(config.Template)
+	if err == nil {
+		if parsed, err := parseVarSpecs(content); err == nil {
+			specs = parsed
+		} else {
+			warnings = append(warnings, fmt.Sprintf("Ignoring invalid template annotations: %v", err))
+		}
+	}
+	f.applyClusterOptions(specs, config, content)
+
+	// Variables written as ${VAR:?message} must be filled in
+	parsed, parseErr := ParseTemplate(content)
+	if parseErr == nil {
+		for _, variable := range parsed.Variables() {
+			if variable.Required {
+				spec := varSpecFor(specs, variable.Name)
+				spec.Required = true
+				specs[variable.Name] = spec
 			}
-			form.AddDropDown(key, gpuOptions, defaultIndex, func(option string, index int) {
-				config.SetEnvVar(key, option)
+		}
+	}
+
+	// Values of the parent configuration, to tell inherited values from overrides
+	var parent *Config
+	if config.Parent != "" {
+		form.SetTitle(fmt.Sprintf("Job Configuration (inherits from %s)", config.Parent))
+		if parent, err = loadConfig(config.Parent); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Failed to load parent configuration: %v", err))
+		}
+	}
+
+	// fieldLabel returns the label of a variable's field, gray when the value is inherited
+	// and marked with ● when it overrides the parent's value
+	fieldLabel := func(key string, failed bool) string {
+		label := variableLabel(varSpecFor(specs, key), failed)
+		if parent == nil || failed {
+			return label
+		}
+		value, _ := config.GetEnvVar(key)
+		if parentValue, exists := parent.GetEnvVar(key); exists && parentValue == value {
+			return "[gray]" + label + "[-]"
+		}
+		return label + " [yellow]●[-]"
+	}
+
+	// fieldOrigin tells where the value of a variable comes from
+	fieldOrigin := func(key string) string {
+		if parent == nil {
+			return ""
+		}
+		value, _ := config.GetEnvVar(key)
+		parentValue, exists := parent.GetEnvVar(key)
+		switch {
+		case !exists:
+			return "Not set by " + config.Parent
+		case parentValue == value:
+			return "Inherited from " + config.Parent
+		case varSpecFor(specs, key).Secret:
+			return "Overrides " + config.Parent
+		}
+		return fmt.Sprintf("Overrides %s (%s)", config.Parent, parentValue)
+	}
+
+	// Help line showing the description and validation state of the focused field
+	fieldHelp := tview.NewTextView().SetDynamicColors(true)
+
+	// Side pane showing the manifest the current values render to
+	preview := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(false)
+	preview.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	var previewLines map[string]int
+
+	updatePreview := func() {
+		if parseErr != nil {
+			preview.SetTitle("Manifest Preview (F2)")
+			preview.SetText(fmt.Sprintf("[red]%s[-]", tview.Escape(parseErr.Error())))
+			return
+		}
+		rendered := renderPreview(parsed, *config, specs)
+		previewLines = rendered.Lines
+		title := "Manifest Preview (F2): [yellow]values[-], [darkcyan]defaults[-]"
+		if len(rendered.Unresolved) > 0 {
+			title += fmt.Sprintf(", [red]%d unresolved[-]", len(rendered.Unresolved))
+		}
+		if config.Overlay != nil {
+			title += ", overlay applied on submit"
+		}
+		preview.SetTitle(title)
+		preview.SetText(rendered.Text)
+	}
+
+	// scrollPreview shows the first use of a variable in the preview
+	scrollPreview := func(key string) {
+		if line, exists := previewLines[key]; exists {
+			preview.ScrollTo(max(line-3, 0), 0)
+		}
+	}
+
+	// Changes are autosaved to a draft until the configuration is saved or applied. A form
+	// opened from a draft starts out modified.
+	draft := f.restoredDraft
+	f.restoredDraft = nil
+	if draft == nil {
+		draft = newConfigDraft(f.configName)
+	}
+	f.draft = draft
+
+	// Track if the form has been modified
+	modified := draft.restored
+
+	// addFields adds a form field for each environment variable
+	// Form fields and the manifest validation problems attributed to each variable
+	fieldItems := make(map[string]tview.FormItem)
+	fieldProblems := make(map[string][]string)
+
+	addFields := func() {
+		for _, env := range config.EnvVars {
+			// Create new variables for the closure
+			key := env.Key
+			spec := varSpecFor(specs, key)
+			fieldItems[key] = addVariableField(form, spec, env.Value, func(text string) {
+				config.SetEnvVar(key, text)
 				modified = true
+				f.scheduleDraftSave(draft, config)
+				// Editing a field clears the problems reported for it
+				delete(fieldProblems, key)
+				setFieldLabel(fieldItems[key], fieldLabel(key, false))
+				showFieldHelp(fieldHelp, spec, text, fieldOrigin(key), nil)
+				updatePreview()
+			}, func() {
+				value, _ := config.GetEnvVar(key)
+				showFieldHelp(fieldHelp, spec, value, fieldOrigin(key), fieldProblems[key])
+				scrollPreview(key)
 			})
-		} else if key == "PRIORITY_CLASS" {
-			priorityOptions := []string{"default-workload-priority", "batch-workload-priority", "short-workload-high-priority"}
-			defaultPriorityIndex := 0
-			for i, option := range priorityOptions {
-				if option == value {
-					defaultPriorityIndex = i
-					break
+			setFieldLabel(fieldItems[key], fieldLabel(key, len(fieldProblems[key]) > 0))
+		}
+	}
+
+	// checkConfig validates all variables and reports problems to the user
+	checkConfig := func() bool {
+		if problems := validateConfig(config, specs); len(problems) > 0 {
+			showError(f.app, f.currentPanel, "Please fix the following fields:\n\n"+strings.Join(problems, "\n"))
+			return false
+		}
+		return true
+	}
+
+	// checkManifest validates the rendered manifest and marks the fields that caused problems
+	checkManifest := func() bool {
+		problems := validateManifest(f.ctx, f.clients, *config)
+
+		for key := range fieldProblems {
+			delete(fieldProblems, key)
+			setFieldLabel(fieldItems[key], fieldLabel(key, false))
+		}
+		if len(problems) == 0 {
+			return true
+		}
+
+		for _, problem := range problems {
+			for _, key := range problem.Variables {
+				if item, exists := fieldItems[key]; exists {
+					fieldProblems[key] = append(fieldProblems[key], problem.Message)
+					setFieldLabel(item, fieldLabel(key, true))
 				}
 			}
-			form.AddDropDown(key, priorityOptions, defaultPriorityIndex, func(option string, index int) {
-				config.SetEnvVar(key, option)
+		}
+		showError(f.app, f.currentPanel, "The job manifest is invalid:\n\n"+formatFieldErrors(problems))
+		return false
+	}
+
+	// Function to edit configuration in the user's editor
+	var editInEditor func()
+
+	// addButtons adds the form buttons after the fields
+	addButtons := func() {
+		form.AddButton("Edit in Editor (e)", editInEditor)
+		form.AddButton("Edit Manifest", func() {
+			if !checkConfig() {
+				return
+			}
+			f.editManifest(config, func() {
 				modified = true
+				f.scheduleDraftSave(draft, config)
+				updatePreview()
 			})
-		} else {
-			form.AddInputField(key, value, 30, nil, func(text string) {
-				config.SetEnvVar(key, text)
-				modified = true
+		})
+		form.AddButton("Save Config (Ctrl+S)", func() {
+			if !checkConfig() {
+				return
+			}
+			f.showSaveConfigDialog(config)
+		})
+		form.AddButton("Validate", func() {
+			if checkConfig() && checkManifest() {
+				showMessage(f.app, f.currentPanel, "The job manifest is valid")
+			}
+		})
+		form.AddButton("Apply (F5)", func() {
+			if !checkConfig() || !checkManifest() {
+				return
+			}
+			f.confirmLint(*config, f.currentPanel, func() {
+				if jobName, err := applyJobConfig(f.ctx, f.clients, f.configName, *config); err != nil {
+					showError(f.app, f.currentPanel, fmt.Sprintf("Failed to apply job: %v", err))
+				} else {
+					if f.configName != "" {
+						recordConfigApplied(f.configName)
+					}
+					showMessage(f.app, form, fmt.Sprintf("Job %s created successfully", jobName))
+					modified = false
+					f.onClose()
+				}
 			})
-		}
+		})
+		form.AddButton("Sweep", func() {
+			if checkConfig() {
+				f.showSweepDialog(config, specs, f.currentPanel)
+			}
+		})
+		form.AddButton("Indexed Job", func() {
+			if checkConfig() {
+				f.showIndexedJobDialog(config, specs, f.currentPanel)
+			}
+		})
+		form.AddButton("Back (Esc)", func() {
+			if modified {
+				modal := tview.NewModal().
+					SetText("You have unsaved changes. Are you sure you want to go back?").
+					AddButtons([]string{"Cancel", "Yes"}).
+					SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+						if buttonLabel == "Yes" {
+							f.discardDraft()
+							f.showConfigList()
+						} else {
+							f.app.SetRoot(f.currentPanel, true)
+						}
+					})
+				f.app.SetRoot(modal, true)
+			} else {
+				f.showConfigList()
+			}
+		})
 	}
 
-	// Function to edit configuration in Vim
-	var editInVim func()
-	editInVim = func() {
+	editInEditor = func() {
 		// Create a temporary file
 		tmpFile, err := os.CreateTemp("", "kstool-config-*.yaml")
 		if err != nil {
@@ -351,8 +545,10 @@ func (f *CreateJobForm) createConfigForm(config *Config) tview.Primitive {
 		}
 		defer os.Remove(tmpFile.Name())
 
-		// Convert current config to YAML with sorted keys
-		yamlData, err := yaml.Marshal(config)
+		// Convert current config to YAML with sorted keys, masking secret values, which are
+		// restored from the form when the file is read back
+		secrets := secretValues(*config)
+		yamlData, err := yaml.Marshal(redactConfig(*config))
 		if err != nil {
 			showError(f.app, form, fmt.Sprintf("Failed to convert config to YAML: %v", err))
 			return
@@ -365,165 +561,82 @@ func (f *CreateJobForm) createConfigForm(config *Config) tview.Primitive {
 		}
 		tmpFile.Close()
 
-		// Save the current terminal state
-		f.app.Suspend(func() {
-			// Launch Vim
-			cmd := exec.Command("vim", tmpFile.Name())
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-
-			if err := cmd.Run(); err != nil {
-				showError(f.app, form, fmt.Sprintf("Failed to run Vim: %v", err))
-				return
-			}
-
-			// Read the edited file
-			editedData, err := os.ReadFile(tmpFile.Name())
-			if err != nil {
-				showError(f.app, form, fmt.Sprintf("Failed to read edited file: %v", err))
-				return
-			}
+		if err := EditFile(f.app, tmpFile.Name(), false); err != nil {
+			showError(f.app, f.currentPanel, err.Error())
+			return
+		}
 
-			// Update the config
-			var newConfig Config
-			if err := yaml.Unmarshal(editedData, &newConfig); err != nil {
-				showError(f.app, form, fmt.Sprintf("Invalid YAML format: %v", err))
-				return
-			}
+		// Read the edited file
+		editedData, err := os.ReadFile(tmpFile.Name())
+		if err != nil {
+			showError(f.app, f.currentPanel, fmt.Sprintf("Failed to read edited file: %v", err))
+			return
+		}
 
-			// Update the config with new values
-			config.EnvVars = newConfig.EnvVars
+		// Update the config
+		var newConfig Config
+		if err := yaml.Unmarshal(editedData, &newConfig); err != nil {
+			showError(f.app, f.currentPanel, fmt.Sprintf("Invalid YAML format: %v", err))
+			return
+		}
 
-			// Clear and rebuild form with sorted keys
-			form.Clear(true)
-			var newSortedKeys []string
-			for _, env := range config.EnvVars {
-				newSortedKeys = append(newSortedKeys, env.Key)
-			}
-			sort.Strings(newSortedKeys)
-
-			// Rebuild form fields in sorted order
-			for _, envVar := range newSortedKeys {
-				value, _ := config.GetEnvVar(envVar)
-				if envVar == "GPU_PRODUCT" {
-					gpuOptions := []string{"NVIDIA-H200", "NVIDIA-H100-80GB-HBM3", "NVIDIA-A100-SXM4-80GB", "NVIDIA-A100-SXM4-40GB-MIG-3g.20gb"}
-					defaultIndex := 0
-					for i, option := range gpuOptions {
-						if option == value {
-							defaultIndex = i
-							break
-						}
-					}
-					form.AddDropDown(envVar, gpuOptions, defaultIndex, func(option string, index int) {
-						config.SetEnvVar(envVar, option)
-						modified = true
-					})
-				} else if envVar == "PRIORITY_CLASS" {
-					priorityOptions := []string{"default-workload-priority", "batch-workload-priority", "short-workload-high-priority"}
-					defaultPriorityIndex := 0
-					for i, option := range priorityOptions {
-						if option == value {
-							defaultPriorityIndex = i
-							break
-						}
-					}
-					form.AddDropDown(envVar, priorityOptions, defaultPriorityIndex, func(option string, index int) {
-						config.SetEnvVar(envVar, option)
-						modified = true
-					})
-				} else {
-					form.AddInputField(envVar, value, 30, nil, func(text string) {
-						config.SetEnvVar(envVar, text)
-						modified = true
-					})
-				}
-			}
+		// Update the config with new values, sorted by key
+		restoreSecrets(newConfig.EnvVars, secrets)
+		config.EnvVars = newConfig.EnvVars
+		sort.Slice(config.EnvVars, func(i, j int) bool {
+			return config.EnvVars[i].Key < config.EnvVars[j].Key
+		})
 
-			// Add back the buttons
-			form.AddButton("Edit in Vim (e)", editInVim)
-			form.AddButton("Save Config (Ctrl+S)", func() {
-				f.showSaveConfigDialog(config)
-				modified = false
-			})
-			form.AddButton("Apply (F5)", func() {
-				if err := applyJobConfig(*config); err != nil {
-					showError(f.app, form, fmt.Sprintf("Failed to apply job: %v", err))
-				} else {
-					showMessage(f.app, form, "Job created successfully")
-					modified = false
-					f.onClose()
-				}
-			})
-			form.AddButton("Back (Esc)", func() {
-				if modified {
-					modal := tview.NewModal().
-						SetText("You have unsaved changes. Are you sure you want to go back?").
-						AddButtons([]string{"Cancel", "Yes"}).
-						SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-							if buttonLabel == "Yes" {
-								f.showConfigList()
-							} else {
-								f.app.SetRoot(form, true)
-							}
-						})
-					f.app.SetRoot(modal, true)
-				} else {
-					f.showConfigList()
-				}
-			})
+		// Rebuild the form with the new values
+		form.Clear(true)
+		addFields()
+		addButtons()
+		updatePreview()
 
-			modified = true
-		})
+		modified = true
+		f.scheduleDraftSave(draft, config)
 	}
 
-	// Add buttons
-	form.AddButton("Edit in Vim (e)", editInVim)
-	form.AddButton("Save Config (Ctrl+S)", func() {
-		f.showSaveConfigDialog(config)
-		modified = false
-	})
-	form.AddButton("Apply (F5)", func() {
-		if err := applyJobConfig(*config); err != nil {
-			showError(f.app, form, fmt.Sprintf("Failed to apply job: %v", err))
-		} else {
-			showMessage(f.app, form, "Job created successfully")
-			modified = false
-			f.onClose()
-		}
-	})
-	form.AddButton("Back (Esc)", func() {
-		if modified {
-			modal := tview.NewModal().
-				SetText("You have unsaved changes. Are you sure you want to go back?").
-				AddButtons([]string{"Cancel", "Yes"}).
-				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-					if buttonLabel == "Yes" {
-						f.showConfigList()
-					} else {
-						f.app.SetRoot(form, true)
-					}
-				})
-			f.app.SetRoot(modal, true)
-		} else {
-			f.showConfigList()
-		}
-	})
+	addFields()
+	addButtons()
+	updatePreview()
 
 	// Add help text at the bottom
 	helpText := tview.NewTextView().
-		SetText("Navigation: Mouse Click - Select field | j/k - Move up/down | Tab/Shift+Tab - Next/Previous | e - Edit in Vim | Ctrl+S - Save | F5 - Apply | Esc - Back").
+		SetText("Navigation: Mouse Click - Select field | j/k - Move up/down | Tab/Shift+Tab - Next/Previous | e - Edit in editor | Ctrl+S - Save | F5 - Apply | F2 - Preview | Esc - Back").
 		SetTextAlign(tview.AlignCenter)
 
+	// The form and, unless hidden, the preview side by side
+	body := tview.NewFlex().AddItem(form, 0, 1, true)
+	if !f.hidePreview {
+		body.AddItem(preview, 0, 1, false)
+	}
+
 	// Create the main layout
 	mainFlex := tview.NewFlex().SetDirection(tview.FlexRow)
-	mainFlex.AddItem(form, 0, 1, true)
+	mainFlex.AddItem(body, 0, 1, true)
+	mainFlex.AddItem(fieldHelp, 1, 0, false)
 	mainFlex.AddItem(helpText, 1, 0, false)
 
 	// Set keyboard shortcuts
 	form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch {
+		case event.Key() == tcell.KeyF2:
+			// Narrow terminals can hide the preview to make room for the form
+			f.hidePreview = !f.hidePreview
+			if f.hidePreview {
+				body.RemoveItem(preview)
+			} else {
+				body.AddItem(preview, 0, 1, false)
+			}
+			return nil
 		case event.Key() == tcell.KeyRune:
+			// Text areas take every character, e.g. for typing scripts
+			if index, _ := form.GetFocusedItemIndex(); index >= 0 {
+				if _, isTextArea := form.GetFormItem(index).(*tview.TextArea); isTextArea {
+					return event
+				}
+			}
 			switch event.Rune() {
 			case 'j':
 				form.SetFocus(form.GetFormItemCount() - 1)
@@ -532,19 +645,26 @@ func (f *CreateJobForm) createConfigForm(config *Config) tview.Primitive {
 				form.SetFocus(0)
 				return nil
 			case 'e':
-				editInVim()
+				editInEditor()
 				return nil
 			}
 		}
 		return event
 	})
 
+	// Callers make the form the root after this returns, so show the warnings after that
+	if len(warnings) > 0 {
+		f.app.QueueUpdateDraw(func() {
+			showError(f.app, mainFlex, strings.Join(warnings, "\n"))
+		})
+	}
+
 	return mainFlex
 }
 
 // showSaveConfigDialog shows a dialog for saving the configuration
 func (f *CreateJobForm) showSaveConfigDialog(config *Config) {
-	// Create a flex container to hold both the text and input field
+	// Create a flex container to hold both the text and input fields
 	flex := tview.NewFlex().SetDirection(tview.FlexRow)
 
 	// Add the text
@@ -553,31 +673,79 @@ func (f *CreateJobForm) showSaveConfigDialog(config *Config) {
 		SetTextAlign(tview.AlignCenter)
 	flex.AddItem(text, 1, 0, false)
 
-	// Add the input field
+	// Add the input fields
 	inputField := tview.NewInputField().
 		SetLabel("Config Name: ").
-		SetFieldWidth(20)
-
-	inputField.SetDoneFunc(func(key tcell.Key) {
-		switch key {
-		case tcell.KeyEnter:
-			name := inputField.GetText()
-			if name == "" {
-				showError(f.app, f.currentPanel, "Configuration name cannot be empty")
-				return
-			}
+		SetFieldWidth(20).
+		SetText(f.configName)
+	descriptionField := tview.NewInputField().
+		SetLabel("Description: ").
+		SetFieldWidth(50).
+		SetText(config.Description)
+
+	// Create a flex container to hold both the input flex and modal
+	mainFlex := tview.NewFlex().SetDirection(tview.FlexRow)
+
+	save := func() {
+		name := strings.TrimSpace(inputField.GetText())
+		if name == "" {
+			showError(f.app, f.currentPanel, "Configuration name cannot be empty")
+			return
+		}
+		if !validBundleName(name) {
+			showError(f.app, mainFlex, fmt.Sprintf("Invalid configuration name %q", name))
+			return
+		}
+
+		write := func() {
+			config.Description = strings.TrimSpace(descriptionField.GetText())
 			if err := f.saveConfig(name, config); err != nil {
 				showError(f.app, f.currentPanel, fmt.Sprintf("Failed to save config: %v", err))
 			} else {
+				f.configName = name
+				f.discardDraft()
 				showMessage(f.app, f.currentPanel, "Configuration saved successfully")
 				f.showConfigList() // Refresh the list
 			}
-		case tcell.KeyEscape:
-			f.app.SetRoot(f.currentPanel, true)
 		}
-	})
+
+		// Saving under the name of another configuration replaces it
+		if name != f.configName && configFileExists(name) {
+			modal := tview.NewModal().
+				SetText(fmt.Sprintf("A configuration named '%s' already exists. Overwrite it?", name)).
+				AddButtons([]string{"Cancel", "Overwrite"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					if buttonLabel == "Overwrite" {
+						write()
+					} else {
+						f.app.SetRoot(mainFlex, true)
+						f.app.SetFocus(inputField)
+					}
+				})
+			f.app.SetRoot(modal, true)
+			return
+		}
+		write()
+	}
+
+	// Enter saves, Tab moves between the fields
+	fieldDone := func(next *tview.InputField) func(key tcell.Key) {
+		return func(key tcell.Key) {
+			switch key {
+			case tcell.KeyEnter:
+				save()
+			case tcell.KeyTab, tcell.KeyBacktab:
+				f.app.SetFocus(next)
+			case tcell.KeyEscape:
+				f.app.SetRoot(f.currentPanel, true)
+			}
+		}
+	}
+	inputField.SetDoneFunc(fieldDone(descriptionField))
+	descriptionField.SetDoneFunc(fieldDone(inputField))
 
 	flex.AddItem(inputField, 1, 0, true)
+	flex.AddItem(descriptionField, 1, 0, false)
 
 	// Create the modal with buttons
 	modal := tview.NewModal().
@@ -585,24 +753,12 @@ func (f *CreateJobForm) showSaveConfigDialog(config *Config) {
 		AddButtons([]string{"Cancel", "Save"}).
 		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
 			if buttonLabel == "Save" {
-				name := inputField.GetText()
-				if name == "" {
-					showError(f.app, f.currentPanel, "Configuration name cannot be empty")
-					return
-				}
-				if err := f.saveConfig(name, config); err != nil {
-					showError(f.app, f.currentPanel, fmt.Sprintf("Failed to save config: %v", err))
-				} else {
-					showMessage(f.app, f.currentPanel, "Configuration saved successfully")
-					f.showConfigList() // Refresh the list
-				}
+				save()
 			} else {
 				f.app.SetRoot(f.currentPanel, true)
 			}
 		})
 
-	// Create a flex container to hold both the input flex and modal
-	mainFlex := tview.NewFlex().SetDirection(tview.FlexRow)
 	mainFlex.AddItem(flex, 0, 1, true)
 	mainFlex.AddItem(modal, 0, 1, false)
 
@@ -610,8 +766,17 @@ func (f *CreateJobForm) showSaveConfigDialog(config *Config) {
 	f.app.SetFocus(inputField)
 }
 
-// saveConfig saves the configuration to a file
+// saveConfig saves the configuration to a file, updating its metadata
 func (f *CreateJobForm) saveConfig(name string, config *Config) error {
+	if err := checkConfigParent(name, config.Parent); err != nil {
+		return err
+	}
+	updateConfigMetadata(name, config)
+	return writeConfig(name, config)
+}
+
+// writeConfig writes the configuration file as it is
+func writeConfig(name string, config *Config) error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return fmt.Errorf("failed to get home directory: %v", err)
@@ -622,8 +787,14 @@ func (f *CreateJobForm) saveConfig(name string, config *Config) error {
 		return config.EnvVars[i].Key < config.EnvVars[j].Key
 	})
 
+	// Configurations with a parent only store what they override
+	saved, err := configOverrides(config)
+	if err != nil {
+		return err
+	}
+
 	configPath := filepath.Join(homeDir, configDir, configListDir, name+".yaml")
-	data, err := yaml.Marshal(config)
+	data, err := yaml.Marshal(saved)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
@@ -643,70 +814,122 @@ func (f *CreateJobForm) showConfigList() {
 		return
 	}
 
+	// Load the metadata of each configuration
+	savedConfigs := make(map[string]*Config)
+	for _, name := range configs {
+		if config, err := loadConfig(name); err == nil {
+			savedConfigs[name] = config
+		}
+	}
+
+	sortOrder := "name"
+	if f.sortByRecentUse {
+		sortConfigsByRecentUse(configs, savedConfigs)
+		sortOrder = "recently used"
+	}
+
 	list := tview.NewList()
 	list.SetBorder(true).
-		SetTitle("Available Configurations").
+		SetTitle(fmt.Sprintf("Available Configurations, by %s (s)%s | (1-9) load, (/) search, (r) rename, (c) duplicate, (d) delete, (x) export, (i) import, (h) history", sortOrder, describeTemplateSource(f.templateSource))).
 		SetTitleAlign(tview.AlignLeft)
 
-	// Add "Create New" option
-	list.AddItem("Create New Configuration", "Create a new job configuration", 'n', func() {
-		// Load base config to get default values
-		config, err := loadBaseConfig()
-		if err != nil {
-			showError(f.app, list, fmt.Sprintf("Failed to load base config: %v", err))
-			return
-		}
-		form := f.createConfigForm(config)
-		f.currentPanel = form
-		f.app.SetRoot(form, true)
-	})
+	// Incremental search over the names and descriptions of the configurations
+	search := tview.NewInputField().SetLabel("Search (/): ")
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(search, 1, 0, false).
+		AddItem(list, 0, 1, true)
+
+	// visible lists the configurations matching the search, in list order
+	var visible []string
+
+	// populate fills the list with the configurations matching the search
+	populate := func() {
+		query := strings.ToLower(strings.TrimSpace(search.GetText()))
+		list.Clear()
+		visible = nil
+
+		// Add "Create New" option
+		list.AddItem("Create New Configuration", "Create a new job configuration", 'n', func() {
+			f.showTemplatePicker(func(template string) {
+				// Load the template to get default values
+				config, err := loadBaseConfig(template)
+				if err != nil {
+					showError(f.app, layout, fmt.Sprintf("Failed to load template: %v", err))
+					return
+				}
+				f.configName = ""
+				form := f.createConfigForm(config)
+				f.currentPanel = form
+				f.app.SetRoot(form, true)
+			})
+		})
 
-	// Add existing configurations
-	for _, name := range configs {
-		configName := name // Create a new variable to avoid closure issues
-		list.AddItem(configName, "Press (l) to load, (d) to delete", 'l', func() {
-			config, err := loadConfig(configName)
-			if err != nil {
-				showError(f.app, list, fmt.Sprintf("Failed to load configuration: %v", err))
-				return
+		// Add existing configurations, the first nine with a number as shortcut
+		for _, name := range configs {
+			configName := name // Create a new variable to avoid closure issues
+			description := "Failed to read configuration"
+			if config := savedConfigs[configName]; config != nil {
+				description = describeConfig(config)
 			}
-			// Show action selection dialog
-			modal := tview.NewModal().
-				SetText(fmt.Sprintf("Configuration: %s\n\nSelect action:", configName)).
-				AddButtons([]string{"Apply", "Change", "Back"}).
-				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-					switch buttonLabel {
-					case "Apply":
-						if err := applyJobConfig(*config); err != nil {
-							showError(f.app, list, fmt.Sprintf("Failed to apply job: %v", err))
-						} else {
-							showMessage(f.app, list, "Job created successfully")
-							f.onClose()
-						}
-					case "Change":
-						form := f.createConfigForm(config)
-						f.currentPanel = form
-						f.app.SetRoot(form, true)
-					case "Back":
-						f.app.SetRoot(list, true)
-					}
-				})
-			f.app.SetRoot(modal, true)
-		})
+			if query != "" && !strings.Contains(strings.ToLower(configName+" "+description), query) {
+				continue
+			}
+
+			visible = append(visible, configName)
+			var shortcut rune
+			if len(visible) <= 9 {
+				shortcut = rune('0' + len(visible))
+			}
+			list.AddItem(configName, description, shortcut, func() {
+				f.showConfigActions(configName, layout)
+			})
+		}
+
+		// Add exit option
+		list.AddItem("Exit", "Return to main view", 'q', f.onClose)
+
+		// Select the best match while searching
+		if query != "" && len(visible) > 0 {
+			list.SetCurrentItem(1)
+		}
 	}
+	populate()
+
+	search.SetChangedFunc(func(string) {
+		populate()
+	})
+	search.SetDoneFunc(func(key tcell.Key) {
+		// Esc clears the search, Enter keeps it
+		if key == tcell.KeyEscape {
+			search.SetText("")
+		}
+		f.app.SetFocus(list)
+	})
+	search.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyDown {
+			f.app.SetFocus(list)
+			return nil
+		}
+		return event
+	})
 
-	// Add exit option
-	list.AddItem("Exit", "Return to main view", 'q', f.onClose)
+	// selected returns the configuration under the cursor, if any
+	selected := func() string {
+		if index := list.GetCurrentItem(); index > 0 && index <= len(visible) { // Skip the "Create New" option
+			return visible[index-1]
+		}
+		return ""
+	}
 
 	// Set keyboard shortcuts for the list
 	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyRune {
 			switch event.Rune() {
+			case '/':
+				f.app.SetFocus(search)
+				return nil
 			case 'd':
-				// Get the current selection
-				index := list.GetCurrentItem()
-				if index > 0 && index <= len(configs) { // Skip the "Create New" option and check if it's a valid config
-					configName := configs[index-1]
+				if configName := selected(); configName != "" {
 					// Show confirmation dialog
 					modal := tview.NewModal().
 						SetText(fmt.Sprintf("Are you sure you want to delete configuration '%s'?", configName)).
@@ -714,18 +937,56 @@ func (f *CreateJobForm) showConfigList() {
 						SetDoneFunc(func(buttonIndex int, buttonLabel string) {
 							if buttonLabel == "Delete" {
 								if err := deleteConfig(configName); err != nil {
-									showError(f.app, list, fmt.Sprintf("Failed to delete configuration: %v", err))
+									showError(f.app, layout, fmt.Sprintf("Failed to delete configuration: %v", err))
 								} else {
 									// Refresh the list
 									f.showConfigList()
 								}
 							} else {
-								f.app.SetRoot(list, true)
+								f.app.SetRoot(layout, true)
 							}
 						})
 					f.app.SetRoot(modal, true)
 				}
 				return nil
+			case 'r':
+				if configName := selected(); configName != "" {
+					f.showConfigNameDialog(fmt.Sprintf("Rename %s", configName), configName, func(newName string) error {
+						if err := renameConfig(configName, newName); err != nil {
+							return err
+						}
+						if f.configName == configName {
+							f.configName = newName
+						}
+						return nil
+					})
+				}
+				return nil
+			case 'c':
+				if configName := selected(); configName != "" {
+					f.showConfigNameDialog(fmt.Sprintf("Duplicate %s", configName), freeName(configName+"-copy", configFileExists), func(newName string) error {
+						return duplicateConfig(configName, newName)
+					})
+				}
+				return nil
+			case 'x':
+				if len(configs) > 0 {
+					f.showExportDialog(configs, selected())
+				}
+				return nil
+			case 'i':
+				f.showImportDialog()
+				return nil
+			case 'h':
+				f.showHistory()
+				return nil
+			case 's':
+				f.sortByRecentUse = !f.sortByRecentUse
+				f.showConfigList()
+				return nil
+			case 'u':
+				f.checkForTemplateUpdate(true)
+				return nil
 			case 'j':
 				// Move to next item
 				currentIndex := list.GetCurrentItem()
@@ -745,26 +1006,80 @@ func (f *CreateJobForm) showConfigList() {
 		return event
 	})
 
-	f.configList = list
-	f.currentPanel = list
-	f.app.SetRoot(list, true)
+	f.configList = layout
+	f.currentPanel = layout
+	f.app.SetRoot(layout, true)
+
+	// Drafts left by an earlier session come first, the template update is offered afterwards
+	if !f.offerDraftRestore() {
+		f.offerTemplateUpdate()
+	}
+}
+
+// showConfigActions asks what to do with a saved configuration
+func (f *CreateJobForm) showConfigActions(configName string, back tview.Primitive) {
+	config, err := loadConfig(configName)
+	if err != nil {
+		showError(f.app, back, fmt.Sprintf("Failed to load configuration: %v", err))
+		return
+	}
+	// Show action selection dialog
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Configuration: %s\n\nSelect action:", configName)).
+		AddButtons([]string{"Apply", "Change", "Derive", "Schedule", "Back"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			switch buttonLabel {
+			case "Apply":
+				if problems := validateManifest(f.ctx, f.clients, *config); len(problems) > 0 {
+					showError(f.app, back, "The job manifest is invalid, use Change to fix it:\n\n"+formatFieldErrors(problems))
+					return
+				}
+				f.confirmLint(*config, back, func() {
+					if jobName, err := applyJobConfig(f.ctx, f.clients, configName, *config); err != nil {
+						showError(f.app, back, fmt.Sprintf("Failed to apply job: %v", err))
+					} else {
+						recordConfigApplied(configName)
+						showMessage(f.app, back, fmt.Sprintf("Job %s created successfully", jobName))
+						f.onClose()
+					}
+				})
+			case "Change":
+				f.configName = configName
+				form := f.createConfigForm(config)
+				f.currentPanel = form
+				f.app.SetRoot(form, true)
+			case "Derive":
+				// A new configuration overriding only some of this one's values
+				f.configName = ""
+				form := f.createConfigForm(deriveConfig(configName, config))
+				f.currentPanel = form
+				f.app.SetRoot(form, true)
+			case "Schedule":
+				// Run the configuration on a schedule as a CronJob
+				f.showCronJobDialog(configName, config, back)
+			case "Back":
+				f.app.SetRoot(back, true)
+			}
+		})
+	f.app.SetRoot(modal, true)
 }
 
 // NewCreateJobForm creates a new job creation form
-func NewCreateJobForm(app *tview.Application, ctx context.Context, onClose func()) *CreateJobForm {
+func NewCreateJobForm(app *tview.Application, ctx context.Context, clients *KubeClients, onClose func()) *CreateJobForm {
 	// Initialize required directories and download base config
 	if err := initializeDirectories(); err != nil {
 		showError(app, nil, fmt.Sprintf("Failed to initialize directories: %v", err))
 		return nil
 	}
 
-	if err := downloadBaseConfig(); err != nil {
-		showError(app, nil, fmt.Sprintf("Failed to download base config: %v", err))
+	templateSource, err := downloadBaseConfig()
+	if err != nil {
+		showError(app, nil, fmt.Sprintf("Failed to install base config: %v", err))
 		return nil
 	}
 
 	// Load base config
-	config, err := loadBaseConfig()
+	config, err := loadBaseConfig(DefaultTemplateName)
 	if err != nil {
 		showError(app, nil, fmt.Sprintf("Failed to load base config: %v", err))
 		return nil
@@ -775,13 +1090,31 @@ func NewCreateJobForm(app *tview.Application, ctx context.Context, onClose func(
 
 	form := &CreateJobForm{
 		app:     app,
-		onClose: onClose,
+		ctx:     ctx,
+		clients: clients,
 		flex:    tview.NewFlex(),
 		config:  config,
+
+		templateSource: templateSource,
+	}
+	// Leaving after a job was applied from the configuration form ends its draft
+	form.onClose = func() {
+		form.discardDraft()
+		onClose()
 	}
 
 	// Show the configuration list
 	form.showConfigList()
+	if templateSource == templateSourceEmbedded {
+		back := form.currentPanel
+		if form.draftPrompt != nil {
+			back = form.draftPrompt
+		}
+		showMessage(app, back, "GitHub could not be reached, so the base template built into KSTool was installed.\n\nThe latest version will be offered once GitHub is reachable.")
+	}
+
+	// Offer newer versions of the base template
+	form.checkForTemplateUpdate(false)
 
 	return form
 }
@@ -796,89 +1129,68 @@ func (f *CreateJobForm) GetRoot() tview.Primitive {
 	return f.currentPanel
 }
 
-// applyJobConfig applies the job configuration using kubectl and envsubst
-func applyJobConfig(config Config) error {
-	// Convert Config to environment variables map
-	envMap := make(map[string]string)
-	for _, env := range config.EnvVars {
-		envMap[env.Key] = env.Value
-	}
-
+// applyJobConfig renders the job template with the configuration and creates the job.
+// name is the saved configuration applied, if any. It returns the name of the created job.
+func applyJobConfig(ctx context.Context, clients *KubeClients, name string, config Config) (string, error) {
 	// Log the job creation
 	user, _ := GetCurrentUser()
 	timestamp := time.Now().Format(time.RFC3339)
-	logMessage := fmt.Sprintf("Timestamp: %s, User: %s, Created Job with Config: %v", timestamp, user, config)
+	logMessage := fmt.Sprintf("Timestamp: %s, User: %s, Created Job with Config: %v", timestamp, user, redactConfig(config))
 	LogToSyslog(logMessage)
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %v", err)
-	}
-
-	templatePath := filepath.Join(homeDir, configDir, "base_apply_template.yaml")
-	content, err := os.ReadFile(templatePath)
+	manifest, err := renderJobConfig(config)
 	if err != nil {
-		return fmt.Errorf("failed to read template config: %v", err)
+		return "", err
 	}
 
-	// Create a temporary file for envsubst
-	tempFile, err := os.CreateTemp("", "config_*.yaml")
+	objects, err := decodeManifest(manifest)
 	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %v", err)
-	}
-	defer os.Remove(tempFile.Name())
-
-	if _, err := tempFile.Write(content); err != nil {
-		return fmt.Errorf("failed to write to temporary file: %v", err)
-	}
-	if err := tempFile.Close(); err != nil {
-		return fmt.Errorf("failed to close temporary file: %v", err)
-	}
-
-	// Set environment variables
-	env := os.Environ()
-	for key, value := range envMap {
-		env = append(env, fmt.Sprintf("%s=%s", key, value))
+		return "", err
 	}
+	return submitJob(ctx, clients, name, config, objects)
+}
 
-	// Run envsubst with the template
-	cmd := exec.Command("envsubst")
-	cmd.Env = env
-
-	// Read from the template file
-	input, err := os.ReadFile(tempFile.Name())
-	if err != nil {
-		return fmt.Errorf("failed to read template file: %v", err)
-	}
-	cmd.Stdin = strings.NewReader(string(input))
+// confirmLint lints the configuration before it is applied. Lint errors block the
+// submission and warnings ask for confirmation; apply is called when the job may be submitted.
+func (f *CreateJobForm) confirmLint(config Config, root tview.Primitive, apply func()) {
+	issues, err := lintConfig(config)
+	f.confirmLintIssues(issues, err, root, apply)
+}
 
-	// Capture output
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to run envsubst: %v", err)
-	}
+// confirmManifestLint lints a manifest before it is submitted, like confirmLint
+func (f *CreateJobForm) confirmManifestLint(manifest []byte, root tview.Primitive, apply func()) {
+	issues, err := LintManifest(manifest)
+	f.confirmLintIssues(issues, err, root, apply)
+}
 
-	// Write the output to a temporary file
-	outputFile, err := os.CreateTemp("", "output_*.yaml")
+// confirmLintIssues blocks on lint errors and asks for confirmation of lint warnings
+func (f *CreateJobForm) confirmLintIssues(issues []LintIssue, err error, root tview.Primitive, apply func()) {
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+		showError(f.app, root, fmt.Sprintf("Failed to lint job: %v", err))
+		return
 	}
-	defer os.Remove(outputFile.Name())
 
-	if _, err := outputFile.Write(output); err != nil {
-		return fmt.Errorf("failed to write output: %v", err)
-	}
-	if err := outputFile.Close(); err != nil {
-		return fmt.Errorf("failed to close output file: %v", err)
+	if hasLintErrors(issues) {
+		showError(f.app, root, "The job would not run correctly:\n\n"+formatLintIssues(issues))
+		return
 	}
 
-	// Apply the configuration using kubectl
-	createCmd := exec.Command("kubectl", "create", "-f", outputFile.Name())
-	if output, err := createCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to create job: %v\nOutput: %s", err, output)
+	if len(issues) > 0 {
+		modal := tview.NewModal().
+			SetText("The job has lint warnings:\n\n" + formatLintIssues(issues) + "\n\nApply anyway?").
+			AddButtons([]string{"Cancel", "Apply"}).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				if buttonLabel == "Apply" {
+					apply()
+				} else {
+					f.app.SetRoot(root, true)
+				}
+			})
+		f.app.SetRoot(modal, true)
+		return
 	}
 
-	return nil
+	apply()
 }
 
 // showError displays an error message
@@ -910,6 +1222,15 @@ func deleteConfig(name string) error {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
 
+	// Configurations inheriting from this one would no longer load
+	children, err := configChildren(name)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("%s is the parent of %s", name, strings.Join(children, ", "))
+	}
+
 	configPath := filepath.Join(homeDir, configDir, configListDir, name+".yaml")
 	if err := os.Remove(configPath); err != nil {
 		return fmt.Errorf("failed to delete config file: %w", err)
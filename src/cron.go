@@ -0,0 +1,18 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+// parseCronSchedule parses a schedule such as "0 2 * * 1-5" or "@daily". It uses the parser
+// of the CronJob controller, so the run times shown are the ones the cluster keeps.
+func parseCronSchedule(spec string) (cron.Schedule, error) {
+	// The API server refuses time zones in the schedule, see CronJobSpec.TimeZone
+	if strings.Contains(spec, "TZ") {
+		return nil, fmt.Errorf("set the time zone in its own field rather than with TZ or CRON_TZ in the schedule")
+	}
+	return cron.ParseStandard(strings.TrimSpace(spec))
+}
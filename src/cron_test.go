@@ -0,0 +1,81 @@
+package src
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		schedule string
+		from     string
+		want     []string
+	}{
+		{name: "steps within a range", schedule: "*/15 9-10 * * *", from: "2026-01-01 10:50",
+			want: []string{"2026-01-02 09:00", "2026-01-02 09:15"}},
+		{name: "step from a start", schedule: "5/20 * * * *", from: "2026-01-01 00:00",
+			want: []string{"2026-01-01 00:05", "2026-01-01 00:25", "2026-01-01 00:45", "2026-01-01 01:05"}},
+		{name: "named months and days", schedule: "0 12 * jan,mar mon-fri", from: "2026-01-30 13:00",
+			want: []string{"2026-03-02 12:00", "2026-03-03 12:00"}},
+		{name: "day of month or day of week", schedule: "0 0 13 * fri", from: "2026-01-01 00:00",
+			want: []string{"2026-01-02 00:00", "2026-01-09 00:00", "2026-01-13 00:00", "2026-01-16 00:00"}},
+		{name: "day of month with any day of week", schedule: "0 0 13 * *", from: "2026-01-01 00:00",
+			want: []string{"2026-01-13 00:00", "2026-02-13 00:00"}},
+		{name: "hourly", schedule: "@hourly", from: "2026-01-01 00:30",
+			want: []string{"2026-01-01 01:00", "2026-01-01 02:00"}},
+		{name: "weekly", schedule: "@weekly", from: "2026-01-01 00:00",
+			want: []string{"2026-01-04 00:00", "2026-01-11 00:00"}},
+		{name: "leap day", schedule: "0 0 29 2 *", from: "2026-01-01 00:00",
+			want: []string{"2028-02-29 00:00"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tt.schedule)
+			if err != nil {
+				t.Fatalf("parseCronSchedule(%q): %v", tt.schedule, err)
+			}
+			next := at(tt.from)
+			for _, want := range tt.want {
+				next = schedule.Next(next)
+				if !next.Equal(at(want)) {
+					t.Fatalf("next run = %s, want %s", next.Format("2006-01-02 15:04"), want)
+				}
+			}
+		})
+	}
+}
+
+func TestCronScheduleNever(t *testing.T) {
+	schedule, err := parseCronSchedule("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Errorf("February 31 runs at %s", next)
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	for _, schedule := range []string{
+		"* * * *",
+		"60 * * * *",
+		"0 0 * * 8",
+		"5-1 * * * *",
+		"0 0 * foo *",
+		"CRON_TZ=Europe/London 0 9 * * *",
+	} {
+		if _, err := parseCronSchedule(schedule); err == nil {
+			t.Errorf("parseCronSchedule(%q) accepted an invalid schedule", schedule)
+		}
+	}
+}
@@ -0,0 +1,556 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// maxCronJobNameLength leaves room for the suffix the CronJob controller adds to job names
+	maxCronJobNameLength = 52
+	// manualJobAnnotation marks jobs started from a CronJob by hand, as kubectl create job --from does
+	manualJobAnnotation = "cronjob.kubernetes.io/instantiate"
+)
+
+// ConcurrencyPolicies lists the CronJob concurrency policies, the default first
+var ConcurrencyPolicies = []string{
+	string(batchv1.ForbidConcurrent),
+	string(batchv1.AllowConcurrent),
+	string(batchv1.ReplaceConcurrent),
+}
+
+// invalidNameChars matches the characters replaced when a configuration name becomes a CronJob name
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// CronJobOptions are the settings of a CronJob created from a configuration
+type CronJobOptions struct {
+	Name     string
+	Schedule string
+	// TimeZone is an IANA time zone name, empty for the time zone of the cluster
+	TimeZone                   string
+	ConcurrencyPolicy          string
+	SuccessfulJobsHistoryLimit int32
+	FailedJobsHistoryLimit     int32
+}
+
+// cronJobName derives a valid CronJob name from a configuration name
+func cronJobName(configName string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(configName), "-")
+	if len(name) > maxCronJobNameLength {
+		name = name[:maxCronJobNameLength]
+	}
+	return strings.Trim(name, "-")
+}
+
+// scheduleLocation returns the location a CronJob's schedule is evaluated in. Without a time
+// zone the controller uses its own, which is UTC on most clusters.
+func scheduleLocation(timeZone *string) (*time.Location, error) {
+	if timeZone == nil || *timeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(*timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", *timeZone)
+	}
+	return loc, nil
+}
+
+// Validate checks the options before the CronJob is created
+func (o CronJobOptions) Validate() error {
+	if len(o.Name) > maxCronJobNameLength {
+		return fmt.Errorf("the name must be at most %d characters", maxCronJobNameLength)
+	}
+	if problems := validation.IsDNS1123Label(o.Name); len(problems) > 0 {
+		return fmt.Errorf("invalid name %q: %s", o.Name, strings.Join(problems, "; "))
+	}
+	if _, err := parseCronSchedule(o.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %v", err)
+	}
+	if _, err := scheduleLocation(&o.TimeZone); err != nil {
+		return err
+	}
+	if o.SuccessfulJobsHistoryLimit < 0 || o.FailedJobsHistoryLimit < 0 {
+		return fmt.Errorf("the number of jobs to keep can't be negative")
+	}
+	return nil
+}
+
+// cronJobFromJob wraps a rendered Job in a CronJob. The CronJob carries the job's labels, so
+// it has the same owner as the jobs it creates.
+func cronJobFromJob(obj *unstructured.Unstructured, options CronJobOptions) (*unstructured.Unstructured, error) {
+	var job batchv1.Job
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job); err != nil {
+		return nil, fmt.Errorf("failed to decode job: %v", err)
+	}
+
+	cronJob := &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      options.Name,
+			Namespace: job.Namespace,
+			Labels:    job.Labels,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   options.Schedule,
+			ConcurrencyPolicy:          batchv1.ConcurrencyPolicy(options.ConcurrencyPolicy),
+			SuccessfulJobsHistoryLimit: &options.SuccessfulJobsHistoryLimit,
+			FailedJobsHistoryLimit:     &options.FailedJobsHistoryLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      job.Labels,
+					Annotations: job.Annotations,
+				},
+				Spec: job.Spec,
+			},
+		},
+	}
+	if options.TimeZone != "" {
+		cronJob.Spec.TimeZone = &options.TimeZone
+	}
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cronJob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode CronJob: %v", err)
+	}
+	return &unstructured.Unstructured{Object: object}, nil
+}
+
+// cronJobObjects renders a configuration and replaces its Job with a CronJob. When secrets
+// are given, their values are first moved into a Secret named after the CronJob, which is
+// returned with the objects.
+func cronJobObjects(config Config, options CronJobOptions, secrets map[string]string) ([]*unstructured.Unstructured, *corev1.Secret, error) {
+	manifest, err := renderJobConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	i, err := findJob(objects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects[i].SetName(options.Name)
+	objects[i].SetGenerateName("")
+	var secret *corev1.Secret
+	if len(secrets) > 0 {
+		if secret, err = injectSecrets(objects, secrets); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	cronJob, err := cronJobFromJob(objects[i], options)
+	if err != nil {
+		return nil, nil, err
+	}
+	objects[i] = cronJob
+	return objects, secret, nil
+}
+
+// submitCronJob creates a CronJob running the configuration on a schedule. Secret values are
+// passed through a Secret owned by the CronJob when the settings ask for it.
+func submitCronJob(ctx context.Context, clients *KubeClients, config Config, options CronJobOptions) error {
+	var secrets map[string]string
+	if settings, err := loadSettings(); err == nil && settings.InjectSecrets {
+		secrets = secretValues(config)
+	}
+	objects, secret, err := cronJobObjects(config, options, secrets)
+	if err != nil {
+		return err
+	}
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Created CronJob %s with schedule %q from Config: %v",
+		time.Now().Format(time.RFC3339), user, options.Name, options.Schedule, redactConfig(config)))
+
+	_, err = clients.createObjectsWithSecret(ctx, objects, secret)
+	return err
+}
+
+// showCronJobDialog asks for the schedule of a CronJob running a saved configuration
+func (f *CreateJobForm) showCronJobDialog(configName string, config *Config, back tview.Primitive) {
+	form := tview.NewForm()
+	form.SetBorder(true).
+		SetTitle(fmt.Sprintf("Schedule %s as a CronJob", configName)).
+		SetTitleAlign(tview.AlignLeft)
+
+	nextRuns := tview.NewTextView().SetDynamicColors(true)
+	nextRuns.SetBorder(true).SetTitle("Next runs").SetTitleAlign(tview.AlignLeft)
+
+	form.AddInputField("Name", cronJobName(configName), 40, nil, nil)
+	form.AddInputField("Schedule", "0 2 * * *", 40, nil, nil)
+	form.AddInputField("Time zone (empty = cluster)", "", 40, nil, nil)
+	form.AddDropDown("Concurrency policy", ConcurrencyPolicies, 0, nil)
+	form.AddInputField("Successful jobs to keep", "3", 10, tview.InputFieldInteger, nil)
+	form.AddInputField("Failed jobs to keep", "1", 10, tview.InputFieldInteger, nil)
+
+	text := func(label string) string {
+		return strings.TrimSpace(form.GetFormItemByLabel(label).(*tview.InputField).GetText())
+	}
+
+	// updateNextRuns previews when the schedule runs as it is typed
+	updateNextRuns := func() {
+		schedule, err := parseCronSchedule(text("Schedule"))
+		if err != nil {
+			nextRuns.SetText("[red]" + tview.Escape(err.Error()) + "[-]")
+			return
+		}
+		timeZone := text("Time zone (empty = cluster)")
+		loc, err := scheduleLocation(&timeZone)
+		if err != nil {
+			nextRuns.SetText("[red]" + tview.Escape(err.Error()) + "[-]")
+			return
+		}
+
+		var lines []string
+		t := time.Now().In(loc)
+		for i := 0; i < 5; i++ {
+			if t = schedule.Next(t); t.IsZero() {
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%s  (%s local)", t.Format("Mon 2006-01-02 15:04 MST"), t.Local().Format("15:04")))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "[red]The schedule never runs[-]")
+		}
+		if timeZone == "" {
+			lines = append(lines, "", "[gray]Without a time zone the schedule follows the cluster, assumed to be UTC[-]")
+		}
+		nextRuns.SetText(strings.Join(lines, "\n"))
+	}
+	for _, label := range []string{"Schedule", "Time zone (empty = cluster)"} {
+		form.GetFormItemByLabel(label).(*tview.InputField).SetChangedFunc(func(string) { updateNextRuns() })
+	}
+	updateNextRuns()
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(nextRuns, 9, 0, false)
+
+	form.AddButton("Create", func() {
+		_, policy := form.GetFormItemByLabel("Concurrency policy").(*tview.DropDown).GetCurrentOption()
+		successful, _ := strconv.Atoi(text("Successful jobs to keep"))
+		failed, _ := strconv.Atoi(text("Failed jobs to keep"))
+		options := CronJobOptions{
+			Name:                       text("Name"),
+			Schedule:                   text("Schedule"),
+			TimeZone:                   text("Time zone (empty = cluster)"),
+			ConcurrencyPolicy:          policy,
+			SuccessfulJobsHistoryLimit: int32(successful),
+			FailedJobsHistoryLimit:     int32(failed),
+		}
+		if err := options.Validate(); err != nil {
+			showError(f.app, layout, err.Error())
+			return
+		}
+		if problems := validateManifest(f.ctx, f.clients, *config); len(problems) > 0 {
+			showError(f.app, layout, "The job manifest is invalid, use Change to fix it:\n\n"+formatFieldErrors(problems))
+			return
+		}
+		objects, _, err := cronJobObjects(*config, options, nil)
+		if err != nil {
+			showError(f.app, layout, err.Error())
+			return
+		}
+		if i, err := findCronJob(objects); err == nil {
+			if problems := checkServerSide(f.ctx, f.clients, objects[i]); len(problems) > 0 {
+				showError(f.app, layout, "The CronJob is invalid:\n\n"+formatFieldErrors(problems))
+				return
+			}
+		}
+
+		f.confirmLint(*config, layout, func() {
+			if err := submitCronJob(f.ctx, f.clients, *config, options); err != nil {
+				showError(f.app, layout, fmt.Sprintf("Failed to create CronJob: %v", err))
+				return
+			}
+			showMessage(f.app, back, fmt.Sprintf("CronJob %s created. Press o in the job list to manage it.", options.Name))
+		})
+	})
+	form.AddButton("Cancel", func() {
+		f.app.SetRoot(back, true)
+	})
+	form.SetCancelFunc(func() {
+		f.app.SetRoot(back, true)
+	})
+
+	f.app.SetRoot(layout, true)
+}
+
+// findCronJob returns the index of the first CronJob among the objects of a manifest
+func findCronJob(objects []*unstructured.Unstructured) (int, error) {
+	for i, obj := range objects {
+		if obj.GroupVersionKind() == batchv1.SchemeGroupVersion.WithKind("CronJob") {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("the manifest contains no CronJob")
+}
+
+// nextCronJobRun returns when a CronJob runs next, the zero time when it doesn't
+func nextCronJobRun(cronJob *batchv1.CronJob, now time.Time) time.Time {
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		return time.Time{}
+	}
+	schedule, err := parseCronSchedule(cronJob.Spec.Schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	loc, err := scheduleLocation(cronJob.Spec.TimeZone)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.Next(now.In(loc))
+}
+
+// formatTimeUntil describes how long until t
+func formatTimeUntil(t time.Time) string {
+	d := time.Until(t)
+	switch {
+	case d < time.Minute:
+		return "in <1m"
+	case d < time.Hour:
+		return fmt.Sprintf("in %dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("in %dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("in %dd", int(d.Hours()/24))
+}
+
+// triggerCronJob starts a job from a CronJob's template right away, like kubectl create job --from
+func triggerCronJob(ctx context.Context, clients *KubeClients, cronJob *batchv1.CronJob) (*batchv1.Job, error) {
+	prefix := cronJob.Name
+	if len(prefix) > 50 {
+		prefix = prefix[:50]
+	}
+
+	annotations := map[string]string{manualJobAnnotation: "manual"}
+	for key, value := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[key] = value
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName:    strings.TrimSuffix(prefix, "-") + "-manual-",
+			Namespace:       cronJob.Namespace,
+			Labels:          cronJob.Spec.JobTemplate.Labels,
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob"))},
+		},
+		Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+	}
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Triggered CronJob: %s",
+		time.Now().Format(time.RFC3339), user, cronJob.Name))
+
+	created, err := clients.Clientset.BatchV1().Jobs(cronJob.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	return created, nil
+}
+
+// setCronJobSuspended suspends or resumes a CronJob
+func setCronJobSuspended(ctx context.Context, clients *KubeClients, cronJob *batchv1.CronJob, suspend bool) error {
+	user, _ := GetCurrentUser()
+	action := "Resumed"
+	if suspend {
+		action = "Suspended"
+	}
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, %s CronJob: %s",
+		time.Now().Format(time.RFC3339), user, action, cronJob.Name))
+
+	patch := fmt.Sprintf(`{"spec":{"suspend":%t}}`, suspend)
+	_, err := clients.Clientset.BatchV1().CronJobs(cronJob.Namespace).Patch(ctx, cronJob.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update CronJob: %w", err)
+	}
+	return nil
+}
+
+// deleteCronJob deletes a CronJob together with its jobs
+func deleteCronJob(ctx context.Context, clients *KubeClients, cronJob *batchv1.CronJob) error {
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Deleted CronJob: %s",
+		time.Now().Format(time.RFC3339), user, cronJob.Name))
+
+	propagation := metav1.DeletePropagationBackground
+	err := clients.Clientset.BatchV1().CronJobs(cronJob.Namespace).Delete(ctx, cronJob.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		return fmt.Errorf("failed to delete CronJob: %w", err)
+	}
+	return nil
+}
+
+// ShowCronJobs lists the CronJobs of the namespace with their last and next runs. The user's
+// own CronJobs can be suspended, triggered and deleted. onClose is called when the view is left.
+func ShowCronJobs(app *tview.Application, ctx context.Context, clients *KubeClients, currentUser string, onClose func()) {
+	table := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	table.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	help := tview.NewTextView().
+		SetText("r - Refresh | s - Suspend/Resume | t - Trigger now | d - Delete | Esc - Back").
+		SetTextAlign(tview.AlignCenter)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(table, 0, 1, true).
+		AddItem(help, 1, 0, false)
+
+	var cronJobs []batchv1.CronJob
+
+	refresh := func() error {
+		list, err := clients.Clientset.BatchV1().CronJobs(clients.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to list CronJobs: %w", err)
+		}
+		cronJobs = list.Items
+
+		table.Clear()
+		for i, header := range []string{"NAME", "SCHEDULE", "STATUS", "ACTIVE", "LAST RUN", "LAST SUCCESS", "NEXT RUN", "OWNER"} {
+			table.SetCell(0, i, tview.NewTableCell(header).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+		}
+		now := time.Now()
+		for i := range cronJobs {
+			cronJob := &cronJobs[i]
+
+			schedule := cronJob.Spec.Schedule
+			if cronJob.Spec.TimeZone != nil {
+				schedule += " " + *cronJob.Spec.TimeZone
+			}
+			status, color := "Active", tcell.ColorGreen
+			if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+				status, color = "Suspended", tcell.ColorGray
+			}
+			lastRun, lastSuccess, nextRun := "-", "-", "-"
+			if t := cronJob.Status.LastScheduleTime; t != nil {
+				lastRun = formatTimeAgo(t.Time)
+			}
+			if t := cronJob.Status.LastSuccessfulTime; t != nil {
+				lastSuccess = formatTimeAgo(t.Time)
+			}
+			if next := nextCronJobRun(cronJob, now); !next.IsZero() {
+				nextRun = fmt.Sprintf("%s (%s)", next.Local().Format("01-02 15:04"), formatTimeUntil(next))
+			}
+
+			table.SetCell(i+1, 0, tview.NewTableCell(cronJob.Name))
+			table.SetCell(i+1, 1, tview.NewTableCell(schedule))
+			table.SetCell(i+1, 2, tview.NewTableCell(status).SetTextColor(color))
+			table.SetCell(i+1, 3, tview.NewTableCell(strconv.Itoa(len(cronJob.Status.Active))))
+			table.SetCell(i+1, 4, tview.NewTableCell(lastRun))
+			table.SetCell(i+1, 5, tview.NewTableCell(lastSuccess))
+			table.SetCell(i+1, 6, tview.NewTableCell(nextRun))
+			table.SetCell(i+1, 7, tview.NewTableCell(cronJob.Labels[userLabel]))
+		}
+		table.SetTitle(fmt.Sprintf("CronJobs in %s: %d", clients.Namespace, len(cronJobs)))
+		return nil
+	}
+
+	if err := refresh(); err != nil {
+		modal := tview.NewModal().
+			SetText(err.Error()).
+			AddButtons([]string{"OK"}).
+			SetDoneFunc(func(int, string) { onClose() })
+		app.SetRoot(modal, true)
+		return
+	}
+
+	// selected returns the selected CronJob if the user owns it, reporting why not otherwise
+	selected := func() *batchv1.CronJob {
+		row, _ := table.GetSelection()
+		if row < 1 || row > len(cronJobs) {
+			return nil
+		}
+		cronJob := &cronJobs[row-1]
+		if cronJob.Labels[userLabel] != currentUser {
+			showError(app, layout, "You can only change your own CronJobs")
+			return nil
+		}
+		return cronJob
+	}
+
+	// confirm runs action after the user agrees, then refreshes the list
+	confirm := func(question, button string, action func() (string, error)) {
+		modal := tview.NewModal().
+			SetText(question).
+			AddButtons([]string{"Cancel", button}).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				if buttonLabel != button {
+					app.SetRoot(layout, true)
+					return
+				}
+				message, err := action()
+				if err == nil {
+					err = refresh()
+				}
+				if err != nil {
+					showError(app, layout, err.Error())
+					return
+				}
+				showMessage(app, layout, message)
+			})
+		app.SetRoot(modal, true)
+	}
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			onClose()
+			return nil
+		}
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+
+		switch event.Rune() {
+		case 'r':
+			if err := refresh(); err != nil {
+				showError(app, layout, err.Error())
+			}
+		case 's':
+			if cronJob := selected(); cronJob != nil {
+				suspend := cronJob.Spec.Suspend == nil || !*cronJob.Spec.Suspend
+				question, button, message := "Suspend CronJob %s? It won't start jobs until resumed.", "Suspend", "CronJob %s suspended"
+				if !suspend {
+					question, button, message = "Resume CronJob %s?", "Resume", "CronJob %s resumed"
+				}
+				confirm(fmt.Sprintf(question, cronJob.Name), button, func() (string, error) {
+					return fmt.Sprintf(message, cronJob.Name), setCronJobSuspended(ctx, clients, cronJob, suspend)
+				})
+			}
+		case 't':
+			if cronJob := selected(); cronJob != nil {
+				confirm(fmt.Sprintf("Start a job from CronJob %s now?", cronJob.Name), "Trigger", func() (string, error) {
+					job, err := triggerCronJob(ctx, clients, cronJob)
+					if err != nil {
+						return "", err
+					}
+					return fmt.Sprintf("Job %s created", job.Name), nil
+				})
+			}
+		case 'd':
+			if cronJob := selected(); cronJob != nil {
+				confirm(fmt.Sprintf("WARNING! Delete CronJob %s and its jobs?", cronJob.Name), "Delete", func() (string, error) {
+					return fmt.Sprintf("CronJob %s deleted", cronJob.Name), deleteCronJob(ctx, clients, cronJob)
+				})
+			}
+		default:
+			return event
+		}
+		return nil
+	})
+
+	app.SetRoot(layout, true)
+}
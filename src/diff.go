@@ -0,0 +1,255 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// diffLine is one line of a line-based diff: ' ' unchanged, '-' removed, '+' added
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// splitLines splits text into lines without their line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// lcsMatches returns, for every line of a that is part of a longest common subsequence
+// with b, the index of the matching line in b
+func lcsMatches(a, b []string) map[int]int {
+	// lengths[i][j] is the LCS length of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := make(map[int]int)
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+// diffLines computes a line-based diff turning a into b
+func diffLines(a, b []string) []diffLine {
+	matches := lcsMatches(a, b)
+
+	var result []diffLine
+	j := 0
+	for i, line := range a {
+		matched, ok := matches[i]
+		if !ok {
+			result = append(result, diffLine{'-', line})
+			continue
+		}
+		for ; j < matched; j++ {
+			result = append(result, diffLine{'+', b[j]})
+		}
+		result = append(result, diffLine{' ', line})
+		j++
+	}
+	for ; j < len(b); j++ {
+		result = append(result, diffLine{'+', b[j]})
+	}
+	return result
+}
+
+// unifiedDiff renders the changes from a to b as a unified diff with the given context lines.
+// It returns an empty string when there are no changes.
+func unifiedDiff(a, b []string, fromName, toName string, context int) string {
+	lines := diffLines(a, b)
+
+	var out strings.Builder
+	changed := false
+	for start := 0; start < len(lines); {
+		// Find the next change
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// Extend the hunk until a gap of more than 2*context unchanged lines
+		last := first
+		for next := first; next < len(lines); next++ {
+			if lines[next].kind != ' ' {
+				last = next
+			} else if next-last > 2*context {
+				break
+			}
+		}
+
+		hunkStart := first - context
+		if hunkStart < start {
+			hunkStart = start
+		}
+		hunkEnd := last + context + 1
+		if hunkEnd > len(lines) {
+			hunkEnd = len(lines)
+		}
+
+		// Line numbers of the hunk in a and b
+		aLine, bLine := 1, 1
+		for _, line := range lines[:hunkStart] {
+			if line.kind != '+' {
+				aLine++
+			}
+			if line.kind != '-' {
+				bLine++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.kind != '+' {
+				aCount++
+			}
+			if line.kind != '-' {
+				bCount++
+			}
+		}
+
+		if !changed {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+			changed = true
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+		for _, line := range lines[hunkStart:hunkEnd] {
+			fmt.Fprintf(&out, "%c%s\n", line.kind, line.text)
+		}
+		start = hunkEnd
+	}
+	return out.String()
+}
+
+// colorizeDiff adds tview color tags to a unified diff
+func colorizeDiff(diff string) string {
+	var out strings.Builder
+	for _, line := range splitLines(diff) {
+		escaped := tview.Escape(line)
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			out.WriteString("[::b]" + escaped + "[::-]")
+		case strings.HasPrefix(line, "@@"):
+			out.WriteString("[aqua]" + escaped + "[-]")
+		case strings.HasPrefix(line, "+"):
+			out.WriteString("[green]" + escaped + "[-]")
+		case strings.HasPrefix(line, "-"):
+			out.WriteString("[red]" + escaped + "[-]")
+		default:
+			out.WriteString(escaped)
+		}
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// Conflict markers written by merge3
+const (
+	conflictLocalMarker  = "<<<<<<< local"
+	conflictSeparator    = "======="
+	conflictRemoteMarker = ">>>>>>> upstream"
+)
+
+// merge3 merges the local and remote changes made to base. Chunks changed differently on
+// both sides are written with conflict markers; the number of conflicts is returned.
+func merge3(base, local, remote []string) ([]string, int) {
+	localMatches := lcsMatches(base, local)
+	remoteMatches := lcsMatches(base, remote)
+
+	var merged []string
+	conflicts := 0
+
+	// mergeChunk merges the lines between two stable lines
+	mergeChunk := func(baseChunk, localChunk, remoteChunk []string) {
+		switch {
+		case equalLines(localChunk, remoteChunk), equalLines(remoteChunk, baseChunk):
+			merged = append(merged, localChunk...)
+		case equalLines(localChunk, baseChunk):
+			merged = append(merged, remoteChunk...)
+		default:
+			conflicts++
+			merged = append(merged, conflictLocalMarker)
+			merged = append(merged, localChunk...)
+			merged = append(merged, conflictSeparator)
+			merged = append(merged, remoteChunk...)
+			merged = append(merged, conflictRemoteMarker)
+		}
+	}
+
+	i, j, k := 0, 0, 0
+	for {
+		// The next base line kept unchanged on both sides
+		next := i
+		for next < len(base) {
+			_, inLocal := localMatches[next]
+			_, inRemote := remoteMatches[next]
+			if inLocal && inRemote {
+				break
+			}
+			next++
+		}
+
+		if next == len(base) {
+			mergeChunk(base[i:], local[j:], remote[k:])
+			break
+		}
+
+		localNext, remoteNext := localMatches[next], remoteMatches[next]
+		mergeChunk(base[i:next], local[j:localNext], remote[k:remoteNext])
+		merged = append(merged, base[next])
+		i, j, k = next+1, localNext+1, remoteNext+1
+	}
+	return merged, conflicts
+}
+
+// equalLines reports whether two line slices are identical
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasConflictMarkers reports whether text still contains unresolved merge conflicts
+func hasConflictMarkers(text string) bool {
+	for _, line := range splitLines(text) {
+		if line == conflictLocalMarker || line == conflictRemoteMarker {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package src
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base := []string{"a", "b", "c", "d", "e"}
+
+	tests := []struct {
+		name          string
+		local, remote []string
+		want          []string
+		conflicts     int
+	}{
+		{
+			name:   "clean merge of separate changes",
+			local:  []string{"a", "B", "c", "d", "e"},
+			remote: []string{"a", "b", "c", "D", "e", "f"},
+			want:   []string{"a", "B", "c", "D", "e", "f"},
+		},
+		{
+			name:   "local changes only",
+			local:  []string{"a", "b", "local", "c", "d"},
+			remote: base,
+			want:   []string{"a", "b", "local", "c", "d"},
+		},
+		{
+			name:   "upstream changes only",
+			local:  base,
+			remote: []string{"upstream", "a", "c", "d", "e"},
+			want:   []string{"upstream", "a", "c", "d", "e"},
+		},
+		{
+			name:   "same change on both sides",
+			local:  []string{"a", "b", "C", "d", "e"},
+			remote: []string{"a", "b", "C", "d", "e"},
+			want:   []string{"a", "b", "C", "d", "e"},
+		},
+		{
+			name:   "conflicting changes",
+			local:  []string{"a", "b", "local", "d", "e"},
+			remote: []string{"a", "b", "upstream", "d", "e"},
+			want: []string{"a", "b",
+				conflictLocalMarker, "local", conflictSeparator, "upstream", conflictRemoteMarker,
+				"d", "e"},
+			conflicts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, conflicts := merge3(base, test.local, test.remote)
+			if conflicts != test.conflicts {
+				t.Errorf("got %d conflicts, want %d", conflicts, test.conflicts)
+			}
+			if !equalLines(merged, test.want) {
+				t.Errorf("merged:\n%s\nwant:\n%s", strings.Join(merged, "\n"), strings.Join(test.want, "\n"))
+			}
+			if hasConflictMarkers(strings.Join(merged, "\n")) != (test.conflicts > 0) {
+				t.Errorf("hasConflictMarkers doesn't match the conflict count")
+			}
+		})
+	}
+}
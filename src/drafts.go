@@ -0,0 +1,268 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rivo/tview"
+	"gopkg.in/yaml.v3"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	draftsDir = "drafts"
+	// draftSaveDelay is how long after a change the draft is written, so typing doesn't write on every key
+	draftSaveDelay = 5 * time.Second
+)
+
+// Draft is the autosaved state of a configuration form, kept until the configuration is
+// saved or applied
+type Draft struct {
+	// ConfigName is the saved configuration being edited, empty for a new one
+	ConfigName string    `yaml:"config,omitempty"`
+	SavedAt    time.Time `yaml:"saved_at"`
+	Config     Config    `yaml:"values"`
+	// Host and PID identify the session writing the draft, so sessions that are still
+	// running aren't offered each other's drafts
+	Host string `yaml:"host,omitempty"`
+	PID  int    `yaml:"pid,omitempty"`
+
+	path string
+}
+
+// configDraft tracks the draft of the configuration form being edited
+type configDraft struct {
+	path string
+	// restored is set when the form was opened from this draft
+	restored bool
+	// pending is set while a save is scheduled
+	pending bool
+	// closed is set once the form was saved, applied or left, the draft must not be written again
+	closed bool
+}
+
+// draftsPath returns the directory holding the drafts
+func draftsPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, configDir, draftsDir), nil
+}
+
+// draftPath returns the path of a new draft of the named configuration, or of a new one. The
+// random part keeps the drafts of sessions editing the same configuration apart.
+func draftPath(configName string) (string, error) {
+	dir, err := draftsPath()
+	if err != nil {
+		return "", err
+	}
+	name := configName
+	if name == "" {
+		name = "new-" + time.Now().Format("20060102-150405")
+	}
+	return filepath.Join(dir, name+"-"+utilrand.String(6)+".yaml"), nil
+}
+
+// newConfigDraft starts the draft of a form editing the named configuration, or a new one
+func newConfigDraft(configName string) *configDraft {
+	path, err := draftPath(configName)
+	if err != nil {
+		return &configDraft{closed: true}
+	}
+	return &configDraft{path: path}
+}
+
+// writeDraft writes a draft file
+func writeDraft(path string, draft *Draft) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %v", err)
+	}
+	data, err := yaml.Marshal(draft)
+	if err != nil {
+		return fmt.Errorf("failed to marshal draft: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write draft: %v", err)
+	}
+	return nil
+}
+
+// listDrafts returns the drafts left on disk, newest first
+func listDrafts() ([]*Draft, error) {
+	dir, err := draftsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read drafts directory: %v", err)
+	}
+
+	var drafts []*Draft
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var draft Draft
+		if err := yaml.Unmarshal(data, &draft); err != nil {
+			continue
+		}
+		draft.path = path
+		drafts = append(drafts, &draft)
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].SavedAt.After(drafts[j].SavedAt)
+	})
+	return drafts, nil
+}
+
+// inRunningSession reports whether the draft is written by a session that is still running,
+// this one included
+func (d *Draft) inRunningSession() bool {
+	host, err := os.Hostname()
+	if d.PID == 0 || err != nil || host != d.Host {
+		return false
+	}
+	if d.PID == os.Getpid() {
+		return true
+	}
+	process, err := os.FindProcess(d.PID)
+	return err == nil && process.Signal(syscall.Signal(0)) == nil
+}
+
+// renameDrafts moves the drafts of a renamed configuration to its new name
+func renameDrafts(oldName, newName string) error {
+	drafts, err := listDrafts()
+	if err != nil {
+		return err
+	}
+	for _, draft := range drafts {
+		if draft.ConfigName != oldName {
+			continue
+		}
+		path, err := draftPath(newName)
+		if err != nil {
+			return err
+		}
+		draft.ConfigName = newName
+		if err := writeDraft(path, draft); err != nil {
+			return err
+		}
+		os.Remove(draft.path)
+	}
+	return nil
+}
+
+// scheduleDraftSave writes the draft of the form shortly after a change
+func (f *CreateJobForm) scheduleDraftSave(draft *configDraft, config *Config) {
+	if draft.pending || draft.closed {
+		return
+	}
+	draft.pending = true
+	configName := f.configName
+
+	time.AfterFunc(draftSaveDelay, func() {
+		// Write on the UI goroutine, which owns the configuration
+		f.app.QueueUpdate(func() {
+			draft.pending = false
+			if draft.closed {
+				return
+			}
+			// Secret values are left out, they are entered again after restoring
+			host, _ := os.Hostname()
+			err := writeDraft(draft.path, &Draft{
+				ConfigName: configName,
+				SavedAt:    time.Now(),
+				Config:     withoutSecrets(*config),
+				Host:       host,
+				PID:        os.Getpid(),
+			})
+			if err != nil {
+				LogToSyslog(fmt.Sprintf("Failed to save draft: %v", err))
+			}
+		})
+	})
+}
+
+// discardDraft removes the draft of the form once it was saved, applied or abandoned
+func (f *CreateJobForm) discardDraft() {
+	if f.draft == nil {
+		return
+	}
+	f.draft.closed = true
+	if f.draft.path != "" {
+		os.Remove(f.draft.path)
+	}
+	f.draft = nil
+}
+
+// offerDraftRestore offers to restore the newest draft left by an earlier session. It
+// returns whether a draft was offered.
+func (f *CreateJobForm) offerDraftRestore() bool {
+	if f.draftsOffered {
+		return false
+	}
+	drafts, err := listDrafts()
+	if err != nil {
+		return false
+	}
+	var draft *Draft
+	for _, candidate := range drafts {
+		if !candidate.inRunningSession() {
+			draft = candidate
+			break
+		}
+	}
+	if draft == nil {
+		return false
+	}
+
+	subject := "a new configuration"
+	if draft.ConfigName != "" {
+		subject = fmt.Sprintf("configuration '%s'", draft.ConfigName)
+	}
+	text := fmt.Sprintf("Unsaved changes to %s from %s were found, left by a session that ended before they were saved.\n\nRestore them?",
+		subject, formatTimeAgo(draft.SavedAt))
+
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"Restore", "Discard", "Later"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			f.draftPrompt = nil
+			switch buttonLabel {
+			case "Restore":
+				f.configName = ""
+				if draft.ConfigName != "" && configFileExists(draft.ConfigName) {
+					f.configName = draft.ConfigName
+				}
+				f.restoredDraft = &configDraft{path: draft.path, restored: true}
+				form := f.createConfigForm(&draft.Config)
+				f.currentPanel = form
+				f.app.SetRoot(form, true)
+			case "Discard":
+				os.Remove(draft.path)
+				f.showConfigList()
+			default:
+				f.draftsOffered = true
+				f.showConfigList()
+			}
+		})
+	f.draftPrompt = modal
+	f.app.SetRoot(modal, true)
+	return true
+}
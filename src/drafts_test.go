@@ -0,0 +1,86 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDraftPath(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	first, err := draftPath("train")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := draftPath("train")
+	if first == second {
+		t.Errorf("two sessions editing train got the same draft %s", first)
+	}
+	if name := filepath.Base(first); !strings.HasPrefix(name, "train-") || !strings.HasSuffix(name, ".yaml") {
+		t.Errorf("unexpected draft file %s", name)
+	}
+	if name, _ := draftPath(""); !strings.HasPrefix(filepath.Base(name), "new-") {
+		t.Errorf("unexpected draft file %s for a new configuration", name)
+	}
+}
+
+func TestListAndRenameDrafts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	now := time.Now()
+	for i, draft := range []*Draft{
+		{ConfigName: "train", SavedAt: now.Add(-time.Hour)},
+		{ConfigName: "train", SavedAt: now},
+		{ConfigName: "eval", SavedAt: now.Add(-time.Minute)},
+	} {
+		path, _ := draftPath(draft.ConfigName)
+		draft.Config.EnvVars = []EnvVar{{Key: "N", Value: string(rune('0' + i))}}
+		if err := writeDraft(path, draft); err != nil {
+			t.Fatalf("writeDraft: %v", err)
+		}
+	}
+
+	if err := renameDrafts("train", "train-v2"); err != nil {
+		t.Fatalf("renameDrafts: %v", err)
+	}
+	drafts, err := listDrafts()
+	if err != nil {
+		t.Fatalf("listDrafts: %v", err)
+	}
+
+	var got []string
+	for _, draft := range drafts {
+		value, _ := draft.Config.GetEnvVar("N")
+		got = append(got, draft.ConfigName+"="+value)
+		if draft.ConfigName == "train-v2" && !strings.HasPrefix(filepath.Base(draft.path), "train-v2-") {
+			t.Errorf("renamed draft kept the file %s", draft.path)
+		}
+	}
+	// Newest first
+	if want := "train-v2=1 eval=2 train-v2=0"; strings.Join(got, " ") != want {
+		t.Errorf("drafts = %s, want %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestDraftInRunningSession(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name  string
+		draft Draft
+		want  bool
+	}{
+		{name: "this session", draft: Draft{Host: host, PID: os.Getpid()}, want: true},
+		{name: "ended session", draft: Draft{Host: host, PID: 1 << 30}},
+		{name: "other host", draft: Draft{Host: host + "-other", PID: os.Getpid()}},
+		{name: "written before sessions were recorded", draft: Draft{}},
+	}
+	for _, tt := range tests {
+		if got := tt.draft.inRunningSession(); got != tt.want {
+			t.Errorf("%s: inRunningSession = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
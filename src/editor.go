@@ -0,0 +1,123 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// editorFallbacks are tried in order when no editor is configured
+var editorFallbacks = []string{"nvim", "vim", "vi", "nano"}
+
+// splitCommandLine splits an editor command such as `code --wait` or `"my editor" -f` into
+// its program and arguments, honouring single and double quotes and backslash escapes
+func splitCommandLine(command string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField, escaped := false, false
+	var quote rune
+
+	for _, c := range command {
+		switch {
+		case escaped:
+			field.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inField = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				field.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inField = true
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(c)
+			inField = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in editor command %q", command)
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// resolveEditor returns the editor program and its arguments from $VISUAL, $EDITOR, the
+// editor setting or the first installed fallback. Editors that aren't installed are skipped.
+func resolveEditor() ([]string, error) {
+	candidates := []string{os.Getenv("VISUAL"), os.Getenv("EDITOR")}
+	if settings, err := loadSettings(); err == nil {
+		candidates = append(candidates, settings.Editor)
+	}
+	candidates = append(candidates, editorFallbacks...)
+
+	for _, candidate := range candidates {
+		command, err := splitCommandLine(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if len(command) == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(command[0]); err == nil {
+			return command, nil
+		}
+	}
+	return nil, fmt.Errorf("no editor found; set $VISUAL, $EDITOR or editor in ~/.kstool/%s, or install one of %s",
+		settingsFile, strings.Join(editorFallbacks, ", "))
+}
+
+// readOnlyArgs returns the arguments that make an editor open files for viewing only
+func readOnlyArgs(program string) []string {
+	switch filepath.Base(program) {
+	case "vim", "nvim", "vi", "view", "gvim", "mvim":
+		return []string{"-R"}
+	case "nano":
+		return []string{"-v"}
+	}
+	return nil
+}
+
+// EditFile opens a file in the user's editor, suspending the TUI until the editor exits.
+// With readOnly the file is opened for viewing when the editor supports it.
+func EditFile(app *tview.Application, path string, readOnly bool) error {
+	editor, err := resolveEditor()
+	if err != nil {
+		return err
+	}
+
+	args := append([]string(nil), editor[1:]...)
+	if readOnly {
+		args = append(args, readOnlyArgs(editor[0])...)
+	}
+	args = append(args, path)
+
+	var runErr error
+	app.Suspend(func() {
+		cmd := exec.Command(editor[0], args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		runErr = cmd.Run()
+	})
+	if runErr != nil {
+		return fmt.Errorf("failed to run %s: %v", filepath.Base(editor[0]), runErr)
+	}
+	return nil
+}
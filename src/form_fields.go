@@ -0,0 +1,150 @@
+package src
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// Strings longer than multilineLength or spanning several lines are edited in a text area
+const (
+	multilineLength = 60
+	textAreaRows    = 6
+)
+
+// addVariableField adds the form field matching the variable's spec and returns it.
+// changed is called with the new value as text, focused when the field gains focus.
+func addVariableField(form *tview.Form, spec *VarSpec, value string, changed func(text string), focused func()) tview.FormItem {
+	label := variableLabel(spec, false)
+
+	fieldType := spec.Type
+	switch {
+	case spec.Secret && fieldType == VarTypeText:
+		// Only input fields can mask their text
+		fieldType = VarTypeString
+	case fieldType == VarTypeString && !spec.Secret && (strings.Contains(value, "\n") || len(value) > multilineLength):
+		fieldType = VarTypeText
+	}
+
+	var item tview.FormItem
+	switch fieldType {
+	case VarTypeEnum:
+		options := spec.Options
+		currentIndex := -1
+		for i, option := range options {
+			if option == value {
+				currentIndex = i
+				break
+			}
+		}
+		// Keep values that aren't among the options selectable instead of silently replacing them
+		if currentIndex == -1 {
+			options = append(append([]string(nil), options...), value)
+			currentIndex = len(options) - 1
+		}
+		optionTexts := make([]string, len(options))
+		for i, option := range options {
+			optionTexts[i] = option
+			if text, exists := spec.OptionLabels[option]; exists {
+				optionTexts[i] = text
+			}
+		}
+		dropDown := tview.NewDropDown().
+			SetLabel(label).
+			SetOptions(optionTexts, nil).
+			SetCurrentOption(currentIndex)
+		dropDown.SetSelectedFunc(func(text string, index int) {
+			if index >= 0 {
+				changed(options[index])
+			}
+		})
+		dropDown.SetFocusFunc(focused)
+		item = dropDown
+	case VarTypeBool:
+		checked, _ := strconv.ParseBool(value)
+		checkbox := tview.NewCheckbox().
+			SetLabel(label).
+			SetChecked(checked).
+			SetChangedFunc(func(checked bool) {
+				changed(strconv.FormatBool(checked))
+			})
+		checkbox.SetFocusFunc(focused)
+		item = checkbox
+	case VarTypeText:
+		textArea := tview.NewTextArea().
+			SetLabel(label).
+			SetText(value, false).
+			SetSize(textAreaRows, 0)
+		textArea.SetChangedFunc(func() {
+			changed(textArea.GetText())
+		})
+		textArea.SetFocusFunc(focused)
+		item = textArea
+	default:
+		inputField := tview.NewInputField().
+			SetLabel(label).
+			SetText(value).
+			SetFieldWidth(30).
+			SetChangedFunc(changed)
+		switch spec.Type {
+		case VarTypeInt:
+			inputField.SetAcceptanceFunc(tview.InputFieldInteger)
+		case VarTypeFloat:
+			inputField.SetAcceptanceFunc(tview.InputFieldFloat)
+		}
+		if spec.Secret {
+			inputField.SetMaskCharacter('*')
+		}
+		inputField.SetFocusFunc(focused)
+		item = inputField
+	}
+
+	form.AddFormItem(item)
+	return item
+}
+
+// variableLabel returns the label of a variable's field, marked red when the field has problems
+func variableLabel(spec *VarSpec, failed bool) string {
+	label := spec.Name
+	if spec.Required {
+		label += "*"
+	}
+	if failed {
+		label = "[red]" + label + " ✗[-]"
+	}
+	return label
+}
+
+// setFieldLabel updates the label of a form field
+func setFieldLabel(item tview.FormItem, label string) {
+	switch field := item.(type) {
+	case *tview.InputField:
+		field.SetLabel(label)
+	case *tview.DropDown:
+		field.SetLabel(label)
+	case *tview.Checkbox:
+		field.SetLabel(label)
+	case *tview.TextArea:
+		field.SetLabel(label)
+	}
+}
+
+// showFieldHelp shows the origin, description and validation state of a variable in the help line.
+// problems are manifest validation errors attributed to the variable.
+func showFieldHelp(help *tview.TextView, spec *VarSpec, value, origin string, problems []string) {
+	text := tview.Escape(spec.Description)
+	if origin != "" {
+		text = strings.TrimSuffix("[gray]"+tview.Escape(origin)+"[-] | "+text, " | ")
+	}
+	if err := spec.Validate(value); err != nil {
+		problems = append([]string{err.Error()}, problems...)
+	}
+	for _, problem := range problems {
+		if text != "" {
+			text += " | "
+		}
+		text += "[red]" + tview.Escape(problem) + "[-]"
+	}
+	help.SetText(text)
+}
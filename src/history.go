@@ -0,0 +1,511 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	historyDir = "history"
+	// maxHistoryEntries is the number of submissions kept, the oldest are removed first
+	maxHistoryEntries = 500
+)
+
+// Outcomes of a submission
+const (
+	outcomeCreated = "created"
+	outcomeFailed  = "failed"
+)
+
+// Submission is a job submission recorded in the history
+type Submission struct {
+	// ID is the name of the history file, it is not stored in the file
+	ID          string    `yaml:"-"`
+	SubmittedAt time.Time `yaml:"submitted_at"`
+	// ConfigName is the saved configuration the job was created from, if any
+	ConfigName   string `yaml:"config,omitempty"`
+	Template     string `yaml:"template,omitempty"`
+	TemplateHash string `yaml:"template_hash,omitempty"`
+	JobName      string `yaml:"job_name,omitempty"`
+	Outcome      string `yaml:"outcome"`
+	Error        string `yaml:"error,omitempty"`
+
+	EnvVars []EnvVar `yaml:"env_vars"`
+	// Overlay is the overlay of the configuration, applied to the rendered manifest
+	Overlay  map[string]interface{} `yaml:"overlay,omitempty"`
+	Manifest string                 `yaml:"manifest"`
+	// Secrets names the secret variables, whose values are masked in EnvVars and Manifest
+	Secrets []string `yaml:"secrets,omitempty"`
+}
+
+// historyPath returns the directory holding the submission history
+func historyPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, configDir, historyDir), nil
+}
+
+// encodeManifest writes objects as a multi-document YAML manifest
+func encodeManifest(objects []*unstructured.Unstructured) ([]byte, error) {
+	var manifest bytes.Buffer
+	for i, obj := range objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %v", obj.GetKind(), err)
+		}
+		if i > 0 {
+			manifest.WriteString("---\n")
+		}
+		manifest.Write(data)
+	}
+	return manifest.Bytes(), nil
+}
+
+// submitJob creates the objects of a job and records the submission in the history.
+// Secret values are passed through a Secret when the settings ask for it, and are never
+// recorded. It returns the name of the created job.
+func submitJob(ctx context.Context, clients *KubeClients, name string, config Config, objects []*unstructured.Unstructured) (string, error) {
+	secrets := secretValues(config)
+	var secret *corev1.Secret
+	if len(secrets) > 0 {
+		if settings, err := loadSettings(); err == nil && settings.InjectSecrets {
+			if secret, err = injectSecrets(objects, secrets); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// Encode the manifest first, creating the objects fills in their namespace
+	manifest, err := encodeManifest(objects)
+	if err != nil {
+		return "", err
+	}
+
+	created, err := clients.createObjectsWithSecret(ctx, objects, secret)
+
+	jobName := ""
+	for _, obj := range created {
+		if obj.GetKind() == "Job" {
+			jobName = obj.GetName()
+			break
+		}
+	}
+
+	submission := &Submission{
+		SubmittedAt:  time.Now(),
+		ConfigName:   name,
+		Template:     config.Template,
+		TemplateHash: templateFingerprint(config.Template),
+		JobName:      jobName,
+		Outcome:      outcomeCreated,
+		EnvVars:      redactConfig(config).EnvVars,
+		Overlay:      redactOverlay(config.Overlay, secrets),
+		Manifest:     redactText(string(manifest), secrets),
+		Secrets:      secretNames(secrets),
+	}
+	if err != nil {
+		submission.Outcome = outcomeFailed
+		submission.Error = redactText(err.Error(), secrets)
+	}
+	if recordErr := recordSubmission(submission); recordErr != nil {
+		LogToSyslog(fmt.Sprintf("Failed to record submission in history: %v", recordErr))
+	}
+
+	return jobName, err
+}
+
+// recordSubmission writes a submission to the history and removes the oldest entries
+// beyond maxHistoryEntries
+func recordSubmission(submission *Submission) error {
+	dir, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	suffix := submission.JobName
+	if suffix == "" {
+		suffix = submission.Outcome
+	}
+	// The random part keeps submissions in the same millisecond, such as the failed jobs of a
+	// sweep, from replacing each other
+	submission.ID = submission.SubmittedAt.Format("20060102-150405.000") + "-" + suffix + "-" + utilrand.String(4)
+
+	data, err := yaml.Marshal(submission)
+	if err != nil {
+		return fmt.Errorf("failed to marshal submission: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, submission.ID+".yaml"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write submission: %v", err)
+	}
+
+	ids, err := listSubmissions()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids[min(len(ids), maxHistoryEntries):] {
+		os.Remove(filepath.Join(dir, id+".yaml"))
+	}
+	return nil
+}
+
+// listSubmissions returns the IDs of the recorded submissions, newest first
+func listSubmissions() ([]string, error) {
+	dir, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %v", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".yaml" {
+			ids = append(ids, strings.TrimSuffix(entry.Name(), ".yaml"))
+		}
+	}
+	// IDs start with the submission time
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// loadSubmission reads a submission from the history
+func loadSubmission(id string) (*Submission, error) {
+	dir, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, id+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read submission: %v", err)
+	}
+
+	var submission Submission
+	if err := yaml.Unmarshal(data, &submission); err != nil {
+		return nil, fmt.Errorf("failed to parse submission: %v", err)
+	}
+	submission.ID = id
+	return &submission, nil
+}
+
+// config returns the configuration the submission was created from. Secret values
+// weren't recorded and are left empty.
+func (s *Submission) config() *Config {
+	config := &Config{
+		Template: s.Template,
+		EnvVars:  append([]EnvVar(nil), s.EnvVars...),
+		Overlay:  s.Overlay,
+	}
+	for _, name := range s.Secrets {
+		config.SetEnvVar(name, "")
+	}
+	return config
+}
+
+// title returns a one-line summary of the submission
+func (s *Submission) title() string {
+	name := s.JobName
+	if name == "" {
+		name = "(no job created)"
+	}
+	return fmt.Sprintf("%s  %s", s.SubmittedAt.Format("2006-01-02 15:04:05"), name)
+}
+
+// describe returns the secondary text of the submission in the history list
+func (s *Submission) describe() string {
+	config := s.ConfigName
+	if config == "" {
+		config = "unsaved configuration"
+	}
+	text := fmt.Sprintf("%s, template %s, %s", config, templateDisplayName(s.Template), s.Outcome)
+	if s.Error != "" {
+		text += ": " + s.Error
+	}
+	return text
+}
+
+// resubmitSubmission creates the recorded manifest again. The job gets a new name when
+// the manifest uses generateName.
+func resubmitSubmission(ctx context.Context, clients *KubeClients, submission *Submission) (string, error) {
+	if len(submission.Secrets) > 0 {
+		return "", fmt.Errorf("the values of %s weren't recorded, press e to enter them and submit from the configuration form",
+			strings.Join(submission.Secrets, ", "))
+	}
+	objects, err := decodeManifest([]byte(submission.Manifest))
+	if err != nil {
+		return "", err
+	}
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Resubmitted Job %s from history",
+		time.Now().Format(time.RFC3339), user, submission.ID))
+
+	return submitJob(ctx, clients, submission.ConfigName, *submission.config(), objects)
+}
+
+// diffBase returns the submission a submission is compared with by default: the previous
+// submission of the same configuration, or else the previous submission
+func diffBase(ids []string, submissions map[string]*Submission, id string) string {
+	current := submissions[id]
+	previous := ""
+	for i, other := range ids {
+		if other != id {
+			continue
+		}
+		for _, older := range ids[i+1:] {
+			if previous == "" {
+				previous = older
+			}
+			if s := submissions[older]; s != nil && current != nil && s.ConfigName == current.ConfigName {
+				return older
+			}
+		}
+	}
+	return previous
+}
+
+// showHistory shows the submission history. Submissions can be opened, compared, edited
+// and resubmitted.
+func (f *CreateJobForm) showHistory() {
+	ids, err := listSubmissions()
+	if err != nil {
+		showError(f.app, f.currentPanel, fmt.Sprintf("Failed to load history: %v", err))
+		return
+	}
+	if len(ids) == 0 {
+		showMessage(f.app, f.currentPanel, "No jobs have been submitted yet")
+		return
+	}
+
+	submissions := make(map[string]*Submission)
+	for _, id := range ids {
+		if submission, err := loadSubmission(id); err == nil {
+			submissions[id] = submission
+		}
+	}
+
+	marked := ""
+	list := tview.NewList()
+	list.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	setTitle := func() {
+		title := "Submission History | (Enter) open, (d) diff, (m) mark for diff, (r) resubmit, (e) edit, (Esc) back"
+		if marked != "" {
+			title += " | marked: " + submissions[marked].title()
+		}
+		list.SetTitle(title)
+	}
+	setTitle()
+
+	for _, id := range ids {
+		submissionID := id
+		submission := submissions[submissionID]
+		if submission == nil {
+			list.AddItem(submissionID, "Failed to read submission", 0, nil)
+			continue
+		}
+		list.AddItem(submission.title(), submission.describe(), 0, func() {
+			f.showSubmission(submission, list)
+		})
+	}
+
+	selected := func() *Submission {
+		index := list.GetCurrentItem()
+		if index < 0 || index >= len(ids) {
+			return nil
+		}
+		return submissions[ids[index]]
+	}
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			f.showConfigList()
+			return nil
+		}
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+
+		submission := selected()
+		switch event.Rune() {
+		case 'm':
+			if submission != nil {
+				if marked == submission.ID {
+					marked = ""
+				} else {
+					marked = submission.ID
+				}
+				setTitle()
+			}
+			return nil
+		case 'd':
+			if submission == nil {
+				return nil
+			}
+			base := marked
+			if base == "" || base == submission.ID {
+				base = diffBase(ids, submissions, submission.ID)
+			}
+			if submissions[base] == nil {
+				showMessage(f.app, list, "There is no earlier submission to compare with")
+				return nil
+			}
+			f.showSubmissionDiff(submissions[base], submission, list)
+			return nil
+		case 'r':
+			if submission != nil {
+				f.confirmResubmit(submission, list)
+			}
+			return nil
+		case 'e':
+			if submission != nil {
+				f.editSubmission(submission)
+			}
+			return nil
+		case 'j':
+			if index := list.GetCurrentItem(); index < list.GetItemCount()-1 {
+				list.SetCurrentItem(index + 1)
+			}
+			return nil
+		case 'k':
+			if index := list.GetCurrentItem(); index > 0 {
+				list.SetCurrentItem(index - 1)
+			}
+			return nil
+		}
+		return event
+	})
+
+	f.currentPanel = list
+	f.app.SetRoot(list, true)
+}
+
+// showSubmission shows the values and manifest of a submission
+func (f *CreateJobForm) showSubmission(submission *Submission, back tview.Primitive) {
+	var text strings.Builder
+	fmt.Fprintf(&text, "[yellow]Submitted:[-] %s\n", submission.SubmittedAt.Format(time.RFC3339))
+	fmt.Fprintf(&text, "[yellow]Job:[-] %s\n", tview.Escape(submission.JobName))
+	fmt.Fprintf(&text, "[yellow]Outcome:[-] %s\n", submission.Outcome)
+	if submission.Error != "" {
+		fmt.Fprintf(&text, "[yellow]Error:[-] %s\n", tview.Escape(submission.Error))
+	}
+	if submission.ConfigName != "" {
+		fmt.Fprintf(&text, "[yellow]Configuration:[-] %s\n", tview.Escape(submission.ConfigName))
+	}
+	templateNote := ""
+	if current := templateFingerprint(submission.Template); current != "" && current != submission.TemplateHash {
+		templateNote = " [red](changed since)[-]"
+	}
+	fmt.Fprintf(&text, "[yellow]Template:[-] %s %s%s\n", templateDisplayName(submission.Template), submission.TemplateHash, templateNote)
+
+	text.WriteString("\n[yellow]Values:[-]\n")
+	for _, env := range submission.EnvVars {
+		fmt.Fprintf(&text, "  %s=%s\n", env.Key, tview.Escape(env.Value))
+	}
+	text.WriteString("\n[yellow]Manifest:[-]\n")
+	text.WriteString(tview.Escape(submission.Manifest))
+
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetText(text.String())
+	view.SetBorder(true).
+		SetTitle(fmt.Sprintf("Submission %s | (r) resubmit, (e) edit, (Esc) back", submission.ID)).
+		SetTitleAlign(tview.AlignLeft)
+
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			f.app.SetRoot(back, true)
+			return nil
+		case event.Key() == tcell.KeyRune && event.Rune() == 'r':
+			f.confirmResubmit(submission, view)
+			return nil
+		case event.Key() == tcell.KeyRune && event.Rune() == 'e':
+			f.editSubmission(submission)
+			return nil
+		}
+		return event
+	})
+
+	f.app.SetRoot(view, true)
+}
+
+// showSubmissionDiff shows how the manifest of a submission differs from an earlier one
+func (f *CreateJobForm) showSubmissionDiff(from, to *Submission, back tview.Primitive) {
+	diff := unifiedDiff(splitLines(from.Manifest), splitLines(to.Manifest), from.ID, to.ID, 3)
+	if diff == "" {
+		diff = "The manifests are identical."
+	}
+
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetText(colorizeDiff(diff))
+	view.SetBorder(true).
+		SetTitle(fmt.Sprintf("%s → %s | (Esc) back", from.title(), to.title())).
+		SetTitleAlign(tview.AlignLeft)
+
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			f.app.SetRoot(back, true)
+			return nil
+		}
+		return event
+	})
+
+	f.app.SetRoot(view, true)
+}
+
+// confirmResubmit asks before creating the manifest of a submission again
+func (f *CreateJobForm) confirmResubmit(submission *Submission, back tview.Primitive) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Submit the manifest of %s again?", submission.title())).
+		AddButtons([]string{"Cancel", "Resubmit"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			if buttonLabel != "Resubmit" {
+				f.app.SetRoot(back, true)
+				return
+			}
+			jobName, err := resubmitSubmission(f.ctx, f.clients, submission)
+			if err != nil {
+				showError(f.app, back, fmt.Sprintf("Failed to resubmit job: %v", err))
+				return
+			}
+			f.showHistory()
+			showMessage(f.app, f.currentPanel, fmt.Sprintf("Job %s created", jobName))
+		})
+	f.app.SetRoot(modal, true)
+}
+
+// editSubmission opens the configuration form with the values of a submission. They are
+// saved under a new name so the configuration they came from isn't overwritten by accident.
+func (f *CreateJobForm) editSubmission(submission *Submission) {
+	f.configName = ""
+	form := f.createConfigForm(submission.config())
+	f.currentPanel = form
+	f.app.SetRoot(form, true)
+}
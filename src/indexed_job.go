@@ -0,0 +1,650 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"gopkg.in/yaml.v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// indexEnvPrefix starts the names of the environment variables holding per-index values
+	indexEnvPrefix = "KSTOOL_INDEX_"
+	// indexValuesAnnotation records the variable values of each index of an indexed job
+	indexValuesAnnotation = "kstool/index-values"
+	// maxJobIndexes guards against creating a huge indexed job by accident
+	maxJobIndexes = 1000
+)
+
+// shells run the script given after their -c option
+var shells = map[string]bool{"sh": true, "bash": true, "dash": true, "ash": true, "ksh": true, "zsh": true}
+
+// shellOptionsPattern matches shell options that include -c, such as -c, -ec or -lc
+var shellOptionsPattern = regexp.MustCompile(`^-[a-z]*c[a-z]*$`)
+
+// indexEnvName returns the environment variable holding the value of a variable for one index
+func indexEnvName(variable string, index int) string {
+	return fmt.Sprintf("%s%s_%d", indexEnvPrefix, variable, index)
+}
+
+// indexExpression is substituted for a per-index variable: the shell reads the value of the
+// pod's index from its environment at run time
+func indexExpression(variable string) string {
+	return fmt.Sprintf("$(printenv %s%s_$JOB_COMPLETION_INDEX)", indexEnvPrefix, variable)
+}
+
+// mappingValue returns the value of a key in a YAML mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// shellScriptPaths returns the field paths of the container command and argument items run
+// as a script by a shell, as in `command: ["bash", "-c", "--"]` followed by the script in
+// `args`. Only there is the per-index expression evaluated; exec-form commands get it as is.
+func shellScriptPaths(content []byte) map[string]bool {
+	scripts := make(map[string]bool)
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil || len(root.Content) == 0 {
+		return scripts
+	}
+	podSpec := mappingValue(mappingValue(mappingValue(root.Content[0], "spec"), "template"), "spec")
+
+	for _, field := range []string{"initContainers", "containers"} {
+		containers := mappingValue(podSpec, field)
+		if containers == nil {
+			continue
+		}
+		for c, container := range containers.Content {
+			// The command line of the container, with the path of each item
+			var argv, paths []string
+			for _, key := range []string{"command", "args"} {
+				items := mappingValue(container, key)
+				if items == nil || items.Kind != yaml.SequenceNode {
+					continue
+				}
+				for i, item := range items.Content {
+					argv = append(argv, item.Value)
+					paths = append(paths, fmt.Sprintf("spec.template.spec.%s[%d].%s[%d]", field, c, key, i))
+				}
+			}
+			if len(argv) == 0 || !shells[path.Base(argv[0])] {
+				continue
+			}
+
+			// The script follows the options, the last of which is -c, and an optional --
+			for i := 1; i < len(argv) && strings.HasPrefix(argv[i], "-"); i++ {
+				if !shellOptionsPattern.MatchString(argv[i]) {
+					continue
+				}
+				script := i + 1
+				if script < len(argv) && argv[script] == "--" {
+					script++
+				}
+				if script < len(argv) {
+					scripts[paths[script]] = true
+				}
+				break
+			}
+		}
+	}
+	return scripts
+}
+
+// checkIndexedVariables reports variables that can't take per-index values because they are
+// used outside a script run by `sh -c` or `bash -c`, where no shell evaluates the expression
+func checkIndexedVariables(content []byte, variables []string) error {
+	paths := variablePaths(content)
+	scripts := shellScriptPaths(content)
+	for _, variable := range variables {
+		for _, path := range paths[variable] {
+			if !scripts[path] {
+				return fmt.Errorf("%s can't vary per index because it is used in %s; only variables in a script run by `sh -c` or `bash -c` can", variable, path)
+			}
+		}
+	}
+	return nil
+}
+
+// indexedJobManifest renders a configuration as an indexed job with one index per point.
+// The varying variables are read from per-index environment variables by the job's shell.
+func indexedJobManifest(config Config, points []SweepPoint, parallelism int) ([]*unstructured.Unstructured, error) {
+	if len(points) == 0 {
+		return nil, fmt.Errorf("an indexed job needs at least one index")
+	}
+	if len(points) > maxJobIndexes {
+		return nil, fmt.Errorf("the job has %d indexes, more than the limit of %d", len(points), maxJobIndexes)
+	}
+
+	content, err := readTemplate(config.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	var variables []string
+	for _, env := range points[0] {
+		variables = append(variables, env.Key)
+	}
+	if err := checkIndexedVariables(content, variables); err != nil {
+		return nil, err
+	}
+
+	// Render with the expressions in place of the varying values
+	rendered := config
+	rendered.EnvVars = append([]EnvVar(nil), config.EnvVars...)
+	for _, variable := range variables {
+		rendered.SetEnvVar(variable, indexExpression(variable))
+	}
+	manifest, err := renderTemplate(content, rendered)
+	if err != nil {
+		return nil, err
+	}
+	if manifest, err = applyOverlay(manifest, config.Overlay); err != nil {
+		return nil, err
+	}
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var env []corev1.EnvVar
+	var values []string
+	for i, point := range points {
+		for _, value := range point {
+			env = append(env, corev1.EnvVar{Name: indexEnvName(value.Key, i), Value: value.Value})
+		}
+		values = append(values, fmt.Sprintf("%d: %s", i, point))
+	}
+
+	found := false
+	for i, obj := range objects {
+		if obj.GroupVersionKind() != batchv1.SchemeGroupVersion.WithKind("Job") {
+			continue
+		}
+		found = true
+
+		var job batchv1.Job
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job); err != nil {
+			return nil, fmt.Errorf("failed to decode job: %v", err)
+		}
+
+		mode := batchv1.IndexedCompletion
+		completions := int32(len(points))
+		parallel := int32(parallelism)
+		if parallel <= 0 || parallel > completions {
+			parallel = completions
+		}
+		job.Spec.CompletionMode = &mode
+		job.Spec.Completions = &completions
+		job.Spec.Parallelism = &parallel
+
+		if job.Annotations == nil {
+			job.Annotations = make(map[string]string)
+		}
+		job.Annotations[indexValuesAnnotation] = strings.Join(values, "\n")
+
+		podSpec := &job.Spec.Template.Spec
+		for c := range podSpec.InitContainers {
+			podSpec.InitContainers[c].Env = append(podSpec.InitContainers[c].Env, env...)
+		}
+		for c := range podSpec.Containers {
+			podSpec.Containers[c].Env = append(podSpec.Containers[c].Env, env...)
+		}
+
+		converted, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&job)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode job: %v", err)
+		}
+		objects[i] = &unstructured.Unstructured{Object: converted}
+	}
+	if !found {
+		return nil, fmt.Errorf("the template contains no job")
+	}
+	return objects, nil
+}
+
+// submitIndexedJob creates an indexed job with one index per point
+func submitIndexedJob(ctx context.Context, clients *KubeClients, name string, config Config, points []SweepPoint, parallelism int) error {
+	objects, err := indexedJobManifest(config, points, parallelism)
+	if err != nil {
+		return err
+	}
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Created indexed Job with %d indexes from Config: %v",
+		time.Now().Format(time.RFC3339), user, len(points), redactConfig(config)))
+
+	_, err = submitJob(ctx, clients, name, config, objects)
+	return err
+}
+
+// parseIndexes parses an index list such as "1,3-5" as used in the job status
+func parseIndexes(text string) map[int]bool {
+	indexes := make(map[int]bool)
+	for _, part := range strings.Split(text, ",") {
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil {
+				continue
+			}
+		}
+		for i := start; i <= end; i++ {
+			indexes[i] = true
+		}
+	}
+	return indexes
+}
+
+// IndexStatus is the state of one index of an indexed job
+type IndexStatus struct {
+	Index  int
+	Status string
+	Pod    string
+	// Attempts is the number of pods created for the index
+	Attempts int
+	Values   string
+}
+
+// jobFinished reports whether a job has completed or failed
+func jobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// indexStatuses returns the state of every index of an indexed job from the job status and its pods
+func indexStatuses(job *batchv1.Job, pods []corev1.Pod) []IndexStatus {
+	completions := 0
+	if job.Spec.Completions != nil {
+		completions = int(*job.Spec.Completions)
+	}
+
+	values := make(map[int]string)
+	for _, line := range strings.Split(job.Annotations[indexValuesAnnotation], "\n") {
+		if index, value, ok := strings.Cut(line, ": "); ok {
+			if i, err := strconv.Atoi(index); err == nil {
+				values[i] = value
+			}
+		}
+	}
+
+	// The most recent pod of each index
+	latest := make(map[int]*corev1.Pod)
+	attempts := make(map[int]int)
+	for i := range pods {
+		pod := &pods[i]
+		index, err := strconv.Atoi(pod.Annotations[batchv1.JobCompletionIndexAnnotation])
+		if err != nil {
+			continue
+		}
+		attempts[index]++
+		if current := latest[index]; current == nil || pod.CreationTimestamp.After(current.CreationTimestamp.Time) {
+			latest[index] = pod
+		}
+	}
+
+	completed := parseIndexes(job.Status.CompletedIndexes)
+	failed := map[int]bool{}
+	if job.Status.FailedIndexes != nil {
+		failed = parseIndexes(*job.Status.FailedIndexes)
+	}
+	finished := jobFinished(job)
+
+	statuses := make([]IndexStatus, completions)
+	for i := range statuses {
+		status := IndexStatus{Index: i, Attempts: attempts[i], Values: values[i]}
+		pod := latest[i]
+		if pod != nil {
+			status.Pod = pod.Name
+		}
+
+		switch {
+		case completed[i]:
+			status.Status = "Succeeded"
+		case failed[i], finished:
+			// A finished job leaves its incomplete indexes failed
+			status.Status = "Failed"
+		case pod != nil:
+			status.Status = string(pod.Status.Phase)
+		default:
+			status.Status = "Waiting"
+		}
+		statuses[i] = status
+	}
+	return statuses
+}
+
+// failedIndexes returns the indexes that failed
+func failedIndexes(statuses []IndexStatus) []int {
+	var indexes []int
+	for _, status := range statuses {
+		if status.Status == "Failed" {
+			indexes = append(indexes, status.Index)
+		}
+	}
+	return indexes
+}
+
+// retryIndexes creates a new indexed job running only the given indexes of a job.
+// Index i of the new job runs with the values of indexes[i] of the original one.
+func retryIndexes(ctx context.Context, clients *KubeClients, original *batchv1.Job, indexes []int) (*batchv1.Job, error) {
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("there are no failed indexes to retry")
+	}
+	sort.Ints(indexes)
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: strings.TrimSuffix(original.Name, "-") + "-retry-",
+			Namespace:    original.Namespace,
+			Labels:       make(map[string]string),
+			Annotations:  make(map[string]string),
+		},
+		Spec: *original.Spec.DeepCopy(),
+	}
+
+	// The controller fills in the selector and its labels for the new job
+	for key, value := range original.Labels {
+		if !isJobControllerLabel(key) {
+			job.Labels[key] = value
+		}
+	}
+	job.Spec.Selector = nil
+	job.Spec.ManualSelector = nil
+	for key := range job.Spec.Template.Labels {
+		if isJobControllerLabel(key) {
+			delete(job.Spec.Template.Labels, key)
+		}
+	}
+
+	completions := int32(len(indexes))
+	job.Spec.Completions = &completions
+	if job.Spec.Parallelism == nil || *job.Spec.Parallelism > completions {
+		job.Spec.Parallelism = &completions
+	}
+
+	// Map the per-index values of the retried indexes to the new indexes
+	values := make(map[int]string)
+	for _, status := range indexStatuses(original, nil) {
+		values[status.Index] = status.Values
+	}
+	var annotation []string
+	for i, index := range indexes {
+		annotation = append(annotation, fmt.Sprintf("%d: %s", i, values[index]))
+	}
+	job.Annotations[indexValuesAnnotation] = strings.Join(annotation, "\n")
+
+	remap := func(env []corev1.EnvVar) []corev1.EnvVar {
+		var kept, indexed []corev1.EnvVar
+		byName := make(map[string]corev1.EnvVar)
+		for _, variable := range env {
+			if strings.HasPrefix(variable.Name, indexEnvPrefix) {
+				byName[variable.Name] = variable
+			} else {
+				kept = append(kept, variable)
+			}
+		}
+		for name, variable := range byName {
+			separator := strings.LastIndex(name, "_")
+			oldIndex, err := strconv.Atoi(name[separator+1:])
+			if err != nil {
+				continue
+			}
+			for i, index := range indexes {
+				if index == oldIndex {
+					variable.Name = name[:separator+1] + strconv.Itoa(i)
+					indexed = append(indexed, variable)
+				}
+			}
+		}
+		sort.Slice(indexed, func(i, j int) bool { return indexed[i].Name < indexed[j].Name })
+		return append(kept, indexed...)
+	}
+	podSpec := &job.Spec.Template.Spec
+	for c := range podSpec.InitContainers {
+		podSpec.InitContainers[c].Env = remap(podSpec.InitContainers[c].Env)
+	}
+	for c := range podSpec.Containers {
+		podSpec.Containers[c].Env = remap(podSpec.Containers[c].Env)
+	}
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Retrying indexes %v of Job: %s",
+		time.Now().Format(time.RFC3339), user, indexes, original.Name))
+
+	created, err := clients.Clientset.BatchV1().Jobs(original.Namespace).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	return created, nil
+}
+
+// isJobControllerLabel reports whether a label is set by the job controller
+func isJobControllerLabel(key string) bool {
+	switch key {
+	case "controller-uid", "job-name", batchv1.ControllerUidLabel, batchv1.JobNameLabel:
+		return true
+	}
+	return false
+}
+
+// showIndexedJobDialog asks for the values each index takes and submits one indexed job
+func (f *CreateJobForm) showIndexedJobDialog(config *Config, specs map[string]*VarSpec, back tview.Primitive) {
+	form := tview.NewForm()
+	form.SetBorder(true).
+		SetTitle("Indexed Job: one index per combination of values, e.g. 1e-3,3e-4 or 1..5").
+		SetTitleAlign(tview.AlignLeft)
+
+	addSweepFields(form, config, specs)
+	form.AddInputField("Parallelism (0 = all)", "0", 10, tview.InputFieldInteger, nil)
+
+	form.AddButton("Submit", func() {
+		axes, err := readSweepAxes(form, config, maxJobIndexes)
+		if err != nil {
+			showError(f.app, form, err.Error())
+			return
+		}
+		points, err := expandSweep(axes, 0, nil, maxJobIndexes)
+		if err != nil {
+			showError(f.app, form, err.Error())
+			return
+		}
+		parallelism, _ := strconv.Atoi(form.GetFormItemByLabel("Parallelism (0 = all)").(*tview.InputField).GetText())
+
+		// Check every index's values, then the manifest of the first index in full
+		var problems []string
+		for i, point := range points {
+			indexConfig := sweepConfig(*config, point)
+			for _, problem := range validateConfig(&indexConfig, specs) {
+				problems = append(problems, fmt.Sprintf("index %d: %s", i, problem))
+			}
+		}
+		if len(problems) > 0 {
+			showError(f.app, form, "Some values are invalid:\n\n"+strings.Join(problems, "\n"))
+			return
+		}
+		if _, err := indexedJobManifest(*config, points, parallelism); err != nil {
+			showError(f.app, form, err.Error())
+			return
+		}
+		first := sweepConfig(*config, points[0])
+		if problems := validateManifest(f.ctx, f.clients, first); len(problems) > 0 {
+			showError(f.app, form, "The job manifest is invalid:\n\n"+formatFieldErrors(problems))
+			return
+		}
+
+		f.confirmLint(first, form, func() {
+			modal := tview.NewModal().
+				SetText(fmt.Sprintf("Submit one indexed job with %d indexes?", len(points))).
+				AddButtons([]string{"Cancel", "Submit"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					if buttonLabel != "Submit" {
+						f.app.SetRoot(form, true)
+						return
+					}
+					if err := submitIndexedJob(f.ctx, f.clients, f.configName, *config, points, parallelism); err != nil {
+						showError(f.app, form, fmt.Sprintf("Failed to apply job: %v", err))
+						return
+					}
+					if f.configName != "" {
+						recordConfigApplied(f.configName)
+					}
+					f.onClose()
+				})
+			f.app.SetRoot(modal, true)
+		})
+	})
+	form.AddButton("Cancel", func() {
+		f.app.SetRoot(back, true)
+	})
+	form.SetCancelFunc(func() {
+		f.app.SetRoot(back, true)
+	})
+
+	f.app.SetRoot(form, true)
+}
+
+// ShowJobIndexes shows the state of every index of an indexed job and, for the user's own
+// jobs, lets them retry the failed ones. onClose is called when the view is left.
+func ShowJobIndexes(app *tview.Application, ctx context.Context, clients *KubeClients, jobName string, owned bool, onClose func()) {
+	table := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	table.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	help := tview.NewTextView().
+		SetText("r - Refresh | t - Retry failed indexes as a new job | Esc - Back").
+		SetTextAlign(tview.AlignCenter)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(table, 0, 1, true).
+		AddItem(help, 1, 0, false)
+
+	var job *batchv1.Job
+	var statuses []IndexStatus
+
+	refresh := func() error {
+		var err error
+		job, err = clients.Clientset.BatchV1().Jobs(clients.Namespace).Get(ctx, jobName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get job: %w", err)
+		}
+		if job.Spec.CompletionMode == nil || *job.Spec.CompletionMode != batchv1.IndexedCompletion {
+			return fmt.Errorf("job %s is not an indexed job", jobName)
+		}
+
+		pods, err := clients.Clientset.CoreV1().Pods(clients.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", batchv1.JobNameLabel, jobName),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get pods: %w", err)
+		}
+		statuses = indexStatuses(job, pods.Items)
+
+		table.Clear()
+		for i, header := range []string{"INDEX", "STATUS", "ATTEMPTS", "POD", "VALUES"} {
+			table.SetCell(0, i, tview.NewTableCell(header).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+		}
+		counts := make(map[string]int)
+		for i, status := range statuses {
+			counts[status.Status]++
+			color := tcell.ColorWhite
+			switch status.Status {
+			case "Succeeded":
+				color = tcell.ColorBlue
+			case "Failed":
+				color = tcell.ColorRed
+			case "Running":
+				color = tcell.ColorGreen
+			}
+			table.SetCell(i+1, 0, tview.NewTableCell(strconv.Itoa(status.Index)))
+			table.SetCell(i+1, 1, tview.NewTableCell(status.Status).SetTextColor(color))
+			table.SetCell(i+1, 2, tview.NewTableCell(strconv.Itoa(status.Attempts)))
+			table.SetCell(i+1, 3, tview.NewTableCell(status.Pod))
+			table.SetCell(i+1, 4, tview.NewTableCell(status.Values))
+		}
+		table.SetTitle(fmt.Sprintf("Job %s: %d indexes, %d succeeded, %d running, %d failed",
+			jobName, len(statuses), counts["Succeeded"], counts["Running"], counts["Failed"]))
+		return nil
+	}
+
+	if err := refresh(); err != nil {
+		modal := tview.NewModal().
+			SetText(err.Error()).
+			AddButtons([]string{"OK"}).
+			SetDoneFunc(func(int, string) { onClose() })
+		app.SetRoot(modal, true)
+		return
+	}
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			onClose()
+			return nil
+		case event.Key() == tcell.KeyRune && event.Rune() == 'r':
+			if err := refresh(); err != nil {
+				showError(app, layout, err.Error())
+			}
+			return nil
+		case event.Key() == tcell.KeyRune && event.Rune() == 't':
+			if !owned {
+				showError(app, layout, "You can only retry your own jobs")
+				return nil
+			}
+			failed := failedIndexes(statuses)
+			if len(failed) == 0 {
+				showMessage(app, layout, "No index has failed")
+				return nil
+			}
+			modal := tview.NewModal().
+				SetText(fmt.Sprintf("Retry indexes %v of %s as a new job?", failed, jobName)).
+				AddButtons([]string{"Cancel", "Retry"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					if buttonLabel != "Retry" {
+						app.SetRoot(layout, true)
+						return
+					}
+					created, err := retryIndexes(ctx, clients, job, failed)
+					if err != nil {
+						showError(app, layout, fmt.Sprintf("Failed to retry indexes: %v", err))
+						return
+					}
+					showMessage(app, layout, fmt.Sprintf("Created job %s for indexes %v", created.Name, failed))
+				})
+			app.SetRoot(modal, true)
+			return nil
+		}
+		return event
+	})
+
+	app.SetRoot(layout, true)
+}
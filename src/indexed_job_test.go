@@ -0,0 +1,91 @@
+package src
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckIndexedVariables(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		err      string
+	}{
+		{
+			name: "script in args after bash -c --",
+			template: `spec:
+  template:
+    spec:
+      containers:
+        - name: main
+          command: ["/bin/bash", "-c", "--"]
+          args:
+            - |
+              python train.py --lr=${LR:-0.1}
+`,
+		},
+		{
+			name: "script in command after sh -ec",
+			template: `spec:
+  template:
+    spec:
+      initContainers:
+        - name: setup
+          command: ["sh", "-ec", "echo ${LR:-0.1}"]
+`,
+		},
+		{
+			name: "exec-form command",
+			template: `spec:
+  template:
+    spec:
+      containers:
+        - name: main
+          command: ["python", "train.py", "--lr=${LR:-0.1}"]
+`,
+			err: "LR can't vary per index because it is used in spec.template.spec.containers[0].command[2]",
+		},
+		{
+			name: "argument after the script",
+			template: `spec:
+  template:
+    spec:
+      containers:
+        - name: main
+          command: ["bash", "-c", "python train.py", "${LR:-0.1}"]
+`,
+			err: "used in spec.template.spec.containers[0].command[3]",
+		},
+		{
+			name: "args without a shell command",
+			template: `spec:
+  template:
+    spec:
+      containers:
+        - name: main
+          args: ["--lr=${LR:-0.1}"]
+`,
+			err: "used in spec.template.spec.containers[0].args[0]",
+		},
+		{
+			name: "outside the containers",
+			template: `metadata:
+  name: train-${LR:-0.1}
+`,
+			err: "used in metadata.name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkIndexedVariables([]byte(tt.template), []string{"LR"})
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("checkIndexedVariables: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("checkIndexedVariables error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
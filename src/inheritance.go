@@ -0,0 +1,124 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// InheritanceCycleError reports saved configurations that inherit from each other
+type InheritanceCycleError struct {
+	Chain []string
+}
+
+func (e *InheritanceCycleError) Error() string {
+	return "configuration inheritance cycle: " + strings.Join(e.Chain, " -> ")
+}
+
+// resolveConfig loads a saved configuration and applies it on top of its parent chain.
+// chain lists the configurations that inherit from name, to detect cycles.
+func resolveConfig(name string, chain []string) (*Config, error) {
+	for i, child := range chain {
+		if child == name {
+			return nil, &InheritanceCycleError{Chain: append(append([]string(nil), chain[i:]...), name)}
+		}
+	}
+
+	config, err := readConfigFile(name)
+	if err != nil || config.Parent == "" {
+		return config, err
+	}
+
+	parent, err := resolveConfig(config.Parent, append(chain, name))
+	if err != nil {
+		var cycleErr *InheritanceCycleError
+		if errors.As(err, &cycleErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to load parent configuration %s: %w", config.Parent, err)
+	}
+
+	if config.Template == "" {
+		config.Template = parent.Template
+	}
+	if config.Overlay == nil && !config.NoOverlay {
+		config.Overlay = parent.Overlay
+	}
+
+	// Variables not overridden by the configuration come from its parent
+	overrides := config.EnvVars
+	config.EnvVars = append([]EnvVar(nil), parent.EnvVars...)
+	for _, env := range overrides {
+		config.SetEnvVar(env.Key, env.Value)
+	}
+	return config, nil
+}
+
+// checkConfigParent reports an error when saving name with the given parent would create a cycle
+func checkConfigParent(name, parent string) error {
+	if parent == "" {
+		return nil
+	}
+	_, err := resolveConfig(parent, []string{name})
+	return err
+}
+
+// configOverrides returns the copy of a configuration that is written to disk: only the
+// variables that differ from its parent, and no template or overlay when it is the parent's.
+func configOverrides(config *Config) (*Config, error) {
+	if config.Parent == "" {
+		saved := *config
+		saved.NoOverlay = false
+		return &saved, nil
+	}
+
+	parent, err := loadConfig(config.Parent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load parent configuration %s: %w", config.Parent, err)
+	}
+
+	saved := *config
+	if templateDisplayName(saved.Template) == templateDisplayName(parent.Template) {
+		saved.Template = ""
+	}
+	// A configuration read from disk has no overlay when it inherits its parent's, so only
+	// NoOverlay, set when the overlay was removed, tells the two apart
+	saved.NoOverlay = config.NoOverlay && parent.Overlay != nil
+	if saved.NoOverlay || reflect.DeepEqual(saved.Overlay, parent.Overlay) {
+		saved.Overlay = nil
+	}
+	saved.EnvVars = nil
+	for _, env := range config.EnvVars {
+		if value, inherited := parent.GetEnvVar(env.Key); !inherited || value != env.Value {
+			saved.EnvVars = append(saved.EnvVars, env)
+		}
+	}
+	return &saved, nil
+}
+
+// configChildren returns the saved configurations that inherit directly from name
+func configChildren(name string) ([]string, error) {
+	configs, err := loadConfigList()
+	if err != nil {
+		return nil, err
+	}
+
+	var children []string
+	for _, other := range configs {
+		if config, err := readConfigFile(other); err == nil && config.Parent == name {
+			children = append(children, other)
+		}
+	}
+	return children, nil
+}
+
+// deriveConfig starts a new configuration that inherits every value from a saved one
+func deriveConfig(parentName string, parent *Config) *Config {
+	return &Config{
+		Parent:   parentName,
+		Template: parent.Template,
+		Overlay:  parent.Overlay,
+		EnvVars:  append([]EnvVar(nil), parent.EnvVars...),
+	}
+}
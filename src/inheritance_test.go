@@ -0,0 +1,144 @@
+package src
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// setupConfigs points the configuration directory at a temporary home
+func setupConfigs(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, configDir, configListDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustWriteConfig saves a configuration or fails the test
+func mustWriteConfig(t *testing.T, name string, config *Config) {
+	t.Helper()
+	if err := writeConfig(name, config); err != nil {
+		t.Fatalf("writeConfig(%s): %v", name, err)
+	}
+}
+
+// mustLoadConfig loads a resolved configuration or fails the test
+func mustLoadConfig(t *testing.T, name string) *Config {
+	t.Helper()
+	config, err := loadConfig(name)
+	if err != nil {
+		t.Fatalf("loadConfig(%s): %v", name, err)
+	}
+	return config
+}
+
+func TestInheritedOverlayKeptByRawRewrites(t *testing.T) {
+	setupConfigs(t)
+	overlay := map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"x": "y"}}}
+	mustWriteConfig(t, "parent", &Config{Template: "base", Overlay: overlay, EnvVars: []EnvVar{{Key: "A", Value: "1"}}})
+	mustWriteConfig(t, "child", &Config{Parent: "parent", EnvVars: []EnvVar{{Key: "B", Value: "2"}}})
+
+	if err := recordConfigApplied("child"); err != nil {
+		t.Fatalf("recordConfigApplied: %v", err)
+	}
+	if err := duplicateConfig("child", "copy"); err != nil {
+		t.Fatalf("duplicateConfig: %v", err)
+	}
+	if err := renameConfig("parent", "renamed"); err != nil {
+		t.Fatalf("renameConfig: %v", err)
+	}
+
+	for _, name := range []string{"child", "copy"} {
+		config := mustLoadConfig(t, name)
+		if !reflect.DeepEqual(config.Overlay, overlay) {
+			t.Errorf("%s overlay = %v, want the inherited %v", name, config.Overlay, overlay)
+		}
+		if config.Parent != "renamed" {
+			t.Errorf("%s parent = %q, want renamed", name, config.Parent)
+		}
+		if raw, _ := readConfigFile(name); raw.NoOverlay || raw.Overlay != nil {
+			t.Errorf("%s was saved with overlay %v and no_overlay %v", name, raw.Overlay, raw.NoOverlay)
+		}
+	}
+	if applied := mustLoadConfig(t, "child"); applied.ApplyCount != 1 {
+		t.Errorf("apply count = %d, want 1", applied.ApplyCount)
+	}
+}
+
+func TestRemovedOverlayKept(t *testing.T) {
+	setupConfigs(t)
+	overlay := map[string]interface{}{"spec": map[string]interface{}{"backoffLimit": 3}}
+	mustWriteConfig(t, "parent", &Config{Template: "base", Overlay: overlay})
+	mustWriteConfig(t, "child", &Config{Parent: "parent"})
+
+	// As the form's Remove Overlay button does
+	child := mustLoadConfig(t, "child")
+	child.Overlay = nil
+	child.NoOverlay = true
+	mustWriteConfig(t, "child", child)
+
+	if err := recordConfigApplied("child"); err != nil {
+		t.Fatalf("recordConfigApplied: %v", err)
+	}
+	if config := mustLoadConfig(t, "child"); config.Overlay != nil {
+		t.Errorf("overlay = %v after removing it", config.Overlay)
+	}
+
+	// Without an overlay on the parent there is nothing to remove
+	mustWriteConfig(t, "parent", &Config{Template: "base"})
+	mustWriteConfig(t, "child", mustLoadConfig(t, "child"))
+	if raw, _ := readConfigFile("child"); raw.NoOverlay {
+		t.Errorf("no_overlay kept although the parent has no overlay")
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	setupConfigs(t)
+	mustWriteConfig(t, "base", &Config{Template: "gpu", EnvVars: []EnvVar{{Key: "A", Value: "1"}, {Key: "B", Value: "1"}}})
+	mustWriteConfig(t, "middle", &Config{Parent: "base", Template: "gpu", EnvVars: []EnvVar{{Key: "A", Value: "1"}, {Key: "B", Value: "2"}}})
+	mustWriteConfig(t, "leaf", &Config{Parent: "middle", EnvVars: []EnvVar{{Key: "C", Value: "3"}}})
+
+	// Only overrides are stored
+	if raw, _ := readConfigFile("middle"); raw.Template != "" || !reflect.DeepEqual(raw.EnvVars, []EnvVar{{Key: "B", Value: "2"}}) {
+		t.Errorf("middle was saved with template %q and variables %v", raw.Template, raw.EnvVars)
+	}
+
+	leaf := mustLoadConfig(t, "leaf")
+	want := []EnvVar{{Key: "A", Value: "1"}, {Key: "B", Value: "2"}, {Key: "C", Value: "3"}}
+	if leaf.Template != "gpu" || !reflect.DeepEqual(leaf.EnvVars, want) {
+		t.Errorf("leaf resolved to template %q and variables %v, want gpu and %v", leaf.Template, leaf.EnvVars, want)
+	}
+
+	// Changes to a parent reach the configurations inheriting from it
+	mustWriteConfig(t, "base", &Config{Template: "gpu", EnvVars: []EnvVar{{Key: "A", Value: "5"}, {Key: "B", Value: "1"}}})
+	if value, _ := mustLoadConfig(t, "leaf").GetEnvVar("A"); value != "5" {
+		t.Errorf("leaf A = %q after changing base, want 5", value)
+	}
+}
+
+func TestConfigInheritanceCycle(t *testing.T) {
+	setupConfigs(t)
+	mustWriteConfig(t, "a", &Config{Template: "gpu"})
+	mustWriteConfig(t, "b", &Config{Parent: "a"})
+
+	err := checkConfigParent("a", "b")
+	var cycleErr *InheritanceCycleError
+	if !errors.As(err, &cycleErr) || !reflect.DeepEqual(cycleErr.Chain, []string{"a", "b", "a"}) {
+		t.Fatalf("checkConfigParent = %v, want the cycle a -> b -> a", err)
+	}
+	if err := checkConfigParent("c", "b"); err != nil {
+		t.Errorf("checkConfigParent(c, b) = %v", err)
+	}
+
+	// A cycle written by hand is reported when loading
+	if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), configDir, configListDir, "a.yaml"), []byte("parent: b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig("b"); !errors.As(err, &cycleErr) {
+		t.Errorf("loadConfig = %v, want an inheritance cycle", err)
+	}
+}
@@ -0,0 +1,132 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlserializer "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// KubeClients bundles the Kubernetes clients used to submit jobs.
+// Tests can fill it with fake clients and a static RESTMapper.
+type KubeClients struct {
+	Clientset kubernetes.Interface
+	Dynamic   dynamic.Interface
+	Mapper    meta.RESTMapper
+	Namespace string
+}
+
+// NewKubeClients creates the clients from a REST config
+func NewKubeClients(cfg *rest.Config, namespace string) (*KubeClients, error) {
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %v", err)
+	}
+
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %v", err)
+	}
+
+	// Resolve kinds to resources lazily so startup doesn't wait on discovery
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+
+	return &KubeClients{
+		Clientset: clientset,
+		Dynamic:   dyn,
+		Mapper:    mapper,
+		Namespace: namespace,
+	}, nil
+}
+
+// decodeManifest splits a (possibly multi-document) YAML manifest into objects
+func decodeManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yamlserializer.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(manifest)))
+
+	var objects []*unstructured.Unstructured
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %v", err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if _, _, err := decoder.Decode(doc, nil, obj); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest: %v", err)
+		}
+		objects = append(objects, obj)
+	}
+
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("manifest contains no objects")
+	}
+	return objects, nil
+}
+
+// createObject creates a single object, defaulting its namespace when it is namespaced
+func (c *KubeClients) createObject(ctx context.Context, obj *unstructured.Unstructured, opts metav1.CreateOptions) (*unstructured.Unstructured, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find resource for %s: %v", gvk.String(), err)
+	}
+
+	var resource dynamic.ResourceInterface
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = c.Namespace
+		}
+		obj.SetNamespace(namespace)
+		resource = c.Dynamic.Resource(mapping.Resource).Namespace(namespace)
+	} else {
+		resource = c.Dynamic.Resource(mapping.Resource)
+	}
+
+	created, err := resource.Create(ctx, obj, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", gvk.Kind, err)
+	}
+	return created, nil
+}
+
+// createManifest decodes a rendered manifest and creates every object in it
+func (c *KubeClients) createManifest(ctx context.Context, manifest []byte) ([]*unstructured.Unstructured, error) {
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return c.createObjects(ctx, objects)
+}
+
+// createObjects creates the objects in order, stopping at the first failure
+func (c *KubeClients) createObjects(ctx context.Context, objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	var created []*unstructured.Unstructured
+	for _, obj := range objects {
+		result, err := c.createObject(ctx, obj, metav1.CreateOptions{})
+		if err != nil {
+			return created, err
+		}
+		created = append(created, result)
+	}
+	return created, nil
+}
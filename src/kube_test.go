@@ -0,0 +1,113 @@
+package src
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	jobsResource       = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+	configMapsResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+)
+
+// newFakeKubeClients returns clients backed by fakes that know Jobs and ConfigMaps
+func newFakeKubeClients() *KubeClients {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	dynamic := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		jobsResource:       "JobList",
+		configMapsResource: "ConfigMapList",
+	})
+	return &KubeClients{
+		Clientset: fake.NewSimpleClientset(),
+		Dynamic:   dynamic,
+		Mapper:    mapper,
+		Namespace: "eidf-test",
+	}
+}
+
+const testManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: train-data
+data:
+  path: $HOME/data
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: train
+  namespace: other
+spec:
+  template:
+    spec:
+      containers:
+        - name: main
+          image: ubuntu:22.04
+      restartPolicy: Never
+`
+
+func TestDecodeManifest(t *testing.T) {
+	objects, err := decodeManifest([]byte(testManifest))
+	if err != nil {
+		t.Fatalf("decodeManifest: %v", err)
+	}
+	if len(objects) != 2 || objects[0].GetKind() != "ConfigMap" || objects[1].GetKind() != "Job" {
+		t.Fatalf("unexpected objects %v", objects)
+	}
+
+	if _, err := decodeManifest([]byte("---\n\n---\n")); err == nil {
+		t.Error("expected an error for a manifest without objects")
+	}
+	if _, err := decodeManifest([]byte("kind: [")); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
+
+func TestCreateManifest(t *testing.T) {
+	clients := newFakeKubeClients()
+	ctx := context.Background()
+
+	created, err := clients.createManifest(ctx, []byte(testManifest))
+	if err != nil {
+		t.Fatalf("createManifest: %v", err)
+	}
+	if len(created) != 2 {
+		t.Fatalf("expected 2 created objects, got %d", len(created))
+	}
+
+	// Objects without a namespace go to the clients' namespace
+	configMap, err := clients.Dynamic.Resource(configMapsResource).Namespace("eidf-test").Get(ctx, "train-data", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("ConfigMap wasn't created in the default namespace: %v", err)
+	}
+	if path, _, _ := unstructured.NestedString(configMap.Object, "data", "path"); path != "$HOME/data" {
+		t.Errorf("unexpected ConfigMap data %q", path)
+	}
+	if _, err := clients.Dynamic.Resource(jobsResource).Namespace("other").Get(ctx, "train", metav1.GetOptions{}); err != nil {
+		t.Errorf("Job wasn't created in its own namespace: %v", err)
+	}
+}
+
+func TestCreateManifestStopsAtFailure(t *testing.T) {
+	clients := newFakeKubeClients()
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: first\n---\napiVersion: example.com/v1\nkind: Unknown\nmetadata:\n  name: second\n"
+
+	created, err := clients.createManifest(context.Background(), []byte(manifest))
+	if err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+	if len(created) != 1 || created[0].GetName() != "first" {
+		t.Errorf("expected only the first object to be created, got %v", created)
+	}
+}
@@ -0,0 +1,175 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Lint severities
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// userLabel records the owner of a job
+const userLabel = "eidf/user"
+
+// LintIssue is a problem found by a lint rule
+type LintIssue struct {
+	Rule     string
+	Severity string
+	Field    string
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s [%s] %s", i.Severity, i.Rule, i.Message)
+	}
+	return fmt.Sprintf("%s [%s] %s: %s", i.Severity, i.Rule, i.Field, i.Message)
+}
+
+// lintRule checks one common mistake in EIDF job manifests
+type lintRule struct {
+	name  string
+	check func(job *batchv1.Job) []LintIssue
+}
+
+// lintRules are run against every Job of a rendered manifest
+var lintRules = []lintRule{
+	{name: "queue-label", check: lintQueueLabel},
+	{name: "user-label", check: lintUserLabel},
+	{name: "gpu-product-selector", check: lintGPUProductSelector},
+	{name: "shm-size-limit", check: lintShmSizeLimit},
+}
+
+// lintQueueLabel requires the Kueue queue label, without which Kueue never admits the job
+func lintQueueLabel(job *batchv1.Job) []LintIssue {
+	if job.Labels[kueueQueueLabel] != "" {
+		return nil
+	}
+	return []LintIssue{{
+		Severity: LintError,
+		Field:    "metadata.labels",
+		Message:  fmt.Sprintf("missing %s label; Kueue will never admit the job", kueueQueueLabel),
+	}}
+}
+
+// lintUserLabel requires the owner label KSTool uses to allow deleting and entering jobs
+func lintUserLabel(job *batchv1.Job) []LintIssue {
+	if job.Labels[userLabel] != "" {
+		return nil
+	}
+	return []LintIssue{{
+		Severity: LintError,
+		Field:    "metadata.labels",
+		Message:  fmt.Sprintf("missing %s label; KSTool won't let you delete or enter the job", userLabel),
+	}}
+}
+
+// lintGPUProductSelector warns about GPU requests that can land on any GPU product
+func lintGPUProductSelector(job *batchv1.Job) []LintIssue {
+	spec := job.Spec.Template.Spec
+	if spec.NodeSelector[gpuProductLabel] != "" {
+		return nil
+	}
+
+	pod := corev1.Pod{Spec: spec}
+	if podGPURequests(&pod) == 0 {
+		return nil
+	}
+	return []LintIssue{{
+		Severity: LintWarning,
+		Field:    "spec.template.spec.nodeSelector",
+		Message:  fmt.Sprintf("GPUs are requested without a %s node selector; the job may run on any GPU product", gpuProductLabel),
+	}}
+}
+
+// lintShmSizeLimit warns about memory-backed /dev/shm volumes without a size limit
+func lintShmSizeLimit(job *batchv1.Job) []LintIssue {
+	spec := job.Spec.Template.Spec
+
+	shmVolumes := make(map[string]bool)
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			for _, mount := range container.VolumeMounts {
+				if mount.MountPath == "/dev/shm" {
+					shmVolumes[mount.Name] = true
+				}
+			}
+		}
+	}
+
+	var issues []LintIssue
+	for i, volume := range spec.Volumes {
+		if !shmVolumes[volume.Name] || volume.EmptyDir == nil || volume.EmptyDir.SizeLimit != nil {
+			continue
+		}
+		issues = append(issues, LintIssue{
+			Severity: LintWarning,
+			Field:    fmt.Sprintf("spec.template.spec.volumes[%d].emptyDir", i),
+			Message:  fmt.Sprintf("/dev/shm volume %q has no sizeLimit; memory-backed volumes count against the node's memory", volume.Name),
+		})
+	}
+	return issues
+}
+
+// LintManifest runs the lint rules against every Job of a rendered manifest
+func LintManifest(manifest []byte) ([]LintIssue, error) {
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	for _, obj := range objects {
+		if obj.GroupVersionKind() != batchv1.SchemeGroupVersion.WithKind("Job") {
+			continue
+		}
+
+		var job batchv1.Job
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job); err != nil {
+			return nil, fmt.Errorf("failed to decode job: %v", err)
+		}
+
+		for _, rule := range lintRules {
+			for _, issue := range rule.check(&job) {
+				issue.Rule = rule.name
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// lintConfig renders a configuration and lints the result
+func lintConfig(config Config) ([]LintIssue, error) {
+	manifest, err := renderJobConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return LintManifest(manifest)
+}
+
+// hasLintErrors reports whether any issue is an error rather than a warning
+func hasLintErrors(issues []LintIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// formatLintIssues lists lint issues one per line
+func formatLintIssues(issues []LintIssue) string {
+	lines := make([]string, len(issues))
+	for i, issue := range issues {
+		lines[i] = issue.String()
+	}
+	return strings.Join(lines, "\n")
+}
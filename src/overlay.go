@@ -0,0 +1,249 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/rivo/tview"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// findJob returns the index of the first Job among the objects of a manifest
+func findJob(objects []*unstructured.Unstructured) (int, error) {
+	for i, obj := range objects {
+		if obj.GroupVersionKind() == batchv1.SchemeGroupVersion.WithKind("Job") {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("the manifest contains no job")
+}
+
+// applyOverlay patches the Job of a rendered manifest with a configuration's overlay. The
+// overlay is a strategic merge patch, so lists such as containers, env and volumes are merged
+// by name rather than replaced.
+func applyOverlay(manifest []byte, overlay map[string]interface{}) ([]byte, error) {
+	if len(overlay) == 0 {
+		return manifest, nil
+	}
+
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	i, err := findJob(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := json.Marshal(objects[i].Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %v", err)
+	}
+	patch, err := json.Marshal(overlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode overlay: %v", err)
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patch, batchv1.Job{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply overlay: %v", err)
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(patched, &object); err != nil {
+		return nil, fmt.Errorf("failed to decode patched job: %v", err)
+	}
+	objects[i].Object = object
+	return encodeManifest(objects)
+}
+
+// createOverlay returns the overlay that turns the Job of a rendered manifest into the Job of
+// an edited one, nil when they are the same. Only changes to the Job can be kept in an overlay.
+func createOverlay(rendered, edited []byte) (map[string]interface{}, error) {
+	original, err := decodeManifest(rendered)
+	if err != nil {
+		return nil, err
+	}
+	modified, err := decodeManifest(edited)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := findJob(original)
+	if err != nil {
+		return nil, err
+	}
+	j, err := findJob(modified)
+	if err != nil {
+		return nil, err
+	}
+	if len(original) != len(modified) || i != j {
+		return nil, fmt.Errorf("an overlay can only change the job, not add or remove other objects")
+	}
+	for k := range original {
+		if k != i && !reflect.DeepEqual(original[k].Object, modified[k].Object) {
+			return nil, fmt.Errorf("an overlay can only change the job, but %s %s was changed", original[k].GetKind(), original[k].GetName())
+		}
+	}
+
+	originalJSON, err := json.Marshal(original[i].Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %v", err)
+	}
+	modifiedJSON, err := json.Marshal(modified[j].Object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job: %v", err)
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(originalJSON, modifiedJSON, batchv1.Job{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute overlay: %v", err)
+	}
+
+	var overlay map[string]interface{}
+	if err := json.Unmarshal(patch, &overlay); err != nil {
+		return nil, fmt.Errorf("failed to decode overlay: %v", err)
+	}
+	if len(overlay) == 0 {
+		return nil, nil
+	}
+	return overlay, nil
+}
+
+// editManifest opens the rendered manifest of a configuration in the user's editor. The edited
+// manifest is validated and can be submitted once, or kept as the configuration's overlay.
+// changed is called when the overlay of the configuration changes.
+func (f *CreateJobForm) editManifest(config *Config, changed func()) {
+	// The overlay is computed against the template alone
+	withoutOverlay := *config
+	withoutOverlay.Overlay = nil
+	base, err := renderJobConfig(withoutOverlay)
+	if err != nil {
+		showError(f.app, f.currentPanel, fmt.Sprintf("Failed to render manifest: %v", err))
+		return
+	}
+	manifest, err := applyOverlay(base, config.Overlay)
+	if err != nil {
+		showError(f.app, f.currentPanel, err.Error())
+		return
+	}
+	f.editManifestText(config, base, manifest, changed)
+}
+
+// editManifestText lets the user edit manifest and decides what to do with the result
+func (f *CreateJobForm) editManifestText(config *Config, base, manifest []byte, changed func()) {
+	tmpFile, err := os.CreateTemp("", "kstool-manifest-*.yaml")
+	if err != nil {
+		showError(f.app, f.currentPanel, fmt.Sprintf("Failed to create temporary file: %v", err))
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	// Secret values are masked in the file and put back when it is read
+	secrets := secretValues(*config)
+	if _, err := tmpFile.Write([]byte(maskSecrets(string(manifest), secrets))); err != nil {
+		tmpFile.Close()
+		showError(f.app, f.currentPanel, fmt.Sprintf("Failed to write to temporary file: %v", err))
+		return
+	}
+	tmpFile.Close()
+
+	if err := EditFile(f.app, tmpFile.Name(), false); err != nil {
+		showError(f.app, f.currentPanel, err.Error())
+		return
+	}
+
+	edited, err := os.ReadFile(tmpFile.Name())
+	if err != nil {
+		showError(f.app, f.currentPanel, fmt.Sprintf("Failed to read edited file: %v", err))
+		return
+	}
+	edited = []byte(unmaskSecrets(string(edited), secrets))
+
+	// askAgain offers to go back to the editor after a problem
+	askAgain := func(message string) {
+		modal := tview.NewModal().
+			SetText(message).
+			AddButtons([]string{"Edit Again", "Discard"}).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				if buttonLabel == "Edit Again" {
+					f.editManifestText(config, base, edited, changed)
+				} else {
+					f.app.SetRoot(f.currentPanel, true)
+				}
+			})
+		f.app.SetRoot(modal, true)
+	}
+
+	objects, err := decodeManifest(edited)
+	if err != nil {
+		askAgain(fmt.Sprintf("The edited manifest is invalid:\n\n%v", err))
+		return
+	}
+	if problems := validateObjects(f.ctx, f.clients, objects); len(problems) > 0 {
+		askAgain("The edited manifest is invalid:\n\n" + formatFieldErrors(problems))
+		return
+	}
+
+	buttons := []string{"Submit", "Save as Overlay", "Edit Again", "Discard"}
+	text := "The edited manifest is valid. Submit it once, or save the changes as an overlay applied whenever this configuration is used?"
+	if bytes.Equal(edited, manifest) {
+		text = "The manifest was not changed."
+	}
+	if config.Overlay != nil {
+		buttons = []string{"Submit", "Save as Overlay", "Remove Overlay", "Edit Again", "Discard"}
+	}
+
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons(buttons).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			switch buttonLabel {
+			case "Submit":
+				f.confirmManifestLint(edited, f.currentPanel, func() {
+					user, _ := GetCurrentUser()
+					LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Created Job from edited manifest with Config: %v",
+						time.Now().Format(time.RFC3339), user, redactConfig(*config)))
+
+					jobName, err := submitJob(f.ctx, f.clients, f.configName, *config, objects)
+					if err != nil {
+						showError(f.app, f.currentPanel, fmt.Sprintf("Failed to apply job: %v", err))
+						return
+					}
+					if f.configName != "" {
+						recordConfigApplied(f.configName)
+					}
+					showMessage(f.app, f.currentPanel, fmt.Sprintf("Job %s created successfully", jobName))
+					f.onClose()
+				})
+			case "Save as Overlay":
+				overlay, err := createOverlay(base, edited)
+				if err != nil {
+					askAgain(fmt.Sprintf("The changes can't be kept as an overlay:\n\n%v", err))
+					return
+				}
+				config.Overlay = overlay
+				config.NoOverlay = false
+				changed()
+				message := "The changes are now the configuration's overlay."
+				if overlay == nil {
+					message = "The manifest matches the template, so the configuration has no overlay."
+				}
+				showMessage(f.app, f.currentPanel, message+" Save the configuration to keep the change.")
+			case "Remove Overlay":
+				config.Overlay = nil
+				config.NoOverlay = true
+				changed()
+				showMessage(f.app, f.currentPanel, "The overlay was removed. Save the configuration to keep the change.")
+			case "Edit Again":
+				f.editManifestText(config, base, edited, changed)
+			default:
+				f.app.SetRoot(f.currentPanel, true)
+			}
+		})
+	f.app.SetRoot(modal, true)
+}
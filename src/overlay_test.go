@@ -0,0 +1,107 @@
+package src
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const overlayManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: train-data
+data:
+  path: /data
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: train
+spec:
+  backoffLimit: 1
+  template:
+    spec:
+      containers:
+        - name: main
+          image: ubuntu:22.04
+          env:
+            - name: A
+              value: "1"
+      restartPolicy: Never
+`
+
+// mustDecodeObjects decodes a manifest into plain objects for comparison
+func mustDecodeObjects(t *testing.T, manifest []byte) []map[string]interface{} {
+	t.Helper()
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		t.Fatalf("decodeManifest: %v", err)
+	}
+	var decoded []map[string]interface{}
+	for _, obj := range objects {
+		decoded = append(decoded, obj.Object)
+	}
+	return decoded
+}
+
+func TestOverlayRoundTrip(t *testing.T) {
+	edited := strings.NewReplacer(
+		"backoffLimit: 1", "backoffLimit: 4",
+		`              value: "1"`, "              value: \"1\"\n            - name: B\n              value: two",
+	).Replace(overlayManifest)
+
+	overlay, err := createOverlay([]byte(overlayManifest), []byte(edited))
+	if err != nil {
+		t.Fatalf("createOverlay: %v", err)
+	}
+
+	// The overlay is saved in the configuration as YAML
+	data, err := yaml.Marshal(Config{Overlay: overlay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Config
+	if err := yaml.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	patched, err := applyOverlay([]byte(overlayManifest), saved.Overlay)
+	if err != nil {
+		t.Fatalf("applyOverlay: %v", err)
+	}
+	if got, want := mustDecodeObjects(t, patched), mustDecodeObjects(t, []byte(edited)); !reflect.DeepEqual(got, want) {
+		t.Errorf("applying the overlay gave\n%v\nwant\n%v", got, want)
+	}
+
+	// A later template change to the same list is merged with the overlay, not replaced by it
+	updated := strings.Replace(overlayManifest, `              value: "1"`, "              value: \"1\"\n            - name: C\n              value: new", 1)
+	patched, err = applyOverlay([]byte(updated), saved.Overlay)
+	if err != nil {
+		t.Fatalf("applyOverlay: %v", err)
+	}
+	for _, want := range []string{"name: B", "name: C", "backoffLimit: 4"} {
+		if !strings.Contains(string(patched), want) {
+			t.Errorf("patched manifest doesn't contain %q:\n%s", want, patched)
+		}
+	}
+}
+
+func TestCreateOverlayUnchanged(t *testing.T) {
+	overlay, err := createOverlay([]byte(overlayManifest), []byte(overlayManifest))
+	if err != nil || overlay != nil {
+		t.Errorf("createOverlay of an unchanged manifest = %v, %v; want no overlay", overlay, err)
+	}
+}
+
+func TestCreateOverlayOnlyChangesTheJob(t *testing.T) {
+	for name, edited := range map[string]string{
+		"changed ConfigMap": strings.Replace(overlayManifest, "path: /data", "path: /other", 1),
+		"removed ConfigMap": overlayManifest[strings.Index(overlayManifest, "---")+4:],
+	} {
+		if _, err := createOverlay([]byte(overlayManifest), []byte(edited)); err == nil {
+			t.Errorf("%s: createOverlay accepted a change outside the job", name)
+		}
+	}
+}
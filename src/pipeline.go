@@ -0,0 +1,1058 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	pipelinesDir    = "pipelines"
+	pipelineRunsDir = "runs"
+	// pipelinePollInterval is how often the jobs of running pipeline steps are checked
+	pipelinePollInterval = 15 * time.Second
+	// pipelineRequestTimeout bounds each request the executor makes to the cluster
+	pipelineRequestTimeout = 30 * time.Second
+
+	// Labels linking the jobs of a pipeline run to their step, so a restarted KSTool finds
+	// a job it created but didn't get to record
+	pipelineRunLabel     = "kstool/pipeline-run"
+	pipelineStepLabel    = "kstool/pipeline-step"
+	pipelineAttemptLabel = "kstool/pipeline-attempt"
+)
+
+// States of pipeline runs and their steps. A run is running, succeeded, failed or cancelled.
+const (
+	stepPending   = "pending"
+	stepRunning   = "running"
+	stepSucceeded = "succeeded"
+	stepFailed    = "failed"
+	// stepSkipped is a step that didn't run because a step it depends on didn't succeed
+	stepSkipped   = "skipped"
+	stepCancelled = "cancelled"
+)
+
+// Pipeline is a set of saved configurations submitted as jobs in the order of their dependencies
+type Pipeline struct {
+	Description string         `yaml:"description,omitempty"`
+	Steps       []PipelineStep `yaml:"steps"`
+}
+
+// PipelineStep submits a saved configuration once the steps it depends on have succeeded
+type PipelineStep struct {
+	Name   string `yaml:"name"`
+	Config string `yaml:"config"`
+	// After lists the steps that must succeed first. Without it a step waits for the step
+	// before it; an empty list starts it right away.
+	After []string `yaml:"after"`
+}
+
+// PipelineRun is one execution of a pipeline. It is saved after every change, so a restarted
+// KSTool resumes it.
+type PipelineRun struct {
+	// ID is the name of the run file, it is not stored in the file
+	ID         string    `yaml:"-"`
+	Pipeline   string    `yaml:"pipeline"`
+	Status     string    `yaml:"status"`
+	StartedAt  time.Time `yaml:"started_at"`
+	FinishedAt time.Time `yaml:"finished_at,omitempty"`
+	// Steps are a copy of the pipeline's steps with their dependencies resolved, so editing
+	// the pipeline doesn't change runs already started
+	Steps []StepRun `yaml:"steps"`
+}
+
+// StepRun is the state of a step in a pipeline run
+type StepRun struct {
+	PipelineStep `yaml:",inline"`
+	Status       string `yaml:"status"`
+	JobName      string `yaml:"job_name,omitempty"`
+	// Attempt counts the submissions of the step; a retried step gets a new job
+	Attempt    int       `yaml:"attempt,omitempty"`
+	StartedAt  time.Time `yaml:"started_at,omitempty"`
+	FinishedAt time.Time `yaml:"finished_at,omitempty"`
+	Error      string    `yaml:"error,omitempty"`
+}
+
+// pipelinesPath returns the directory holding the pipeline definitions
+func pipelinesPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, configDir, pipelinesDir), nil
+}
+
+// pipelinePath returns the path of a pipeline definition
+func pipelinePath(name string) (string, error) {
+	dir, err := pipelinesPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".yaml"), nil
+}
+
+// listPipelines returns the names of the pipeline definitions
+func listPipelines() ([]string, error) {
+	dir, err := pipelinesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipelines directory: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".yaml" {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// loadPipeline reads and checks a pipeline definition
+func loadPipeline(name string) (*Pipeline, error) {
+	path, err := pipelinePath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline: %v", err)
+	}
+
+	var pipeline Pipeline
+	if err := yaml.Unmarshal(data, &pipeline); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline: %v", err)
+	}
+	if err := pipeline.Validate(); err != nil {
+		return nil, err
+	}
+	return &pipeline, nil
+}
+
+// dependencies returns the names of the steps each step waits for
+func (p *Pipeline) dependencies() [][]string {
+	dependencies := make([][]string, len(p.Steps))
+	for i, step := range p.Steps {
+		switch {
+		case step.After != nil:
+			dependencies[i] = step.After
+		case i > 0:
+			dependencies[i] = []string{p.Steps[i-1].Name}
+		default:
+			dependencies[i] = []string{}
+		}
+	}
+	return dependencies
+}
+
+// Validate checks the steps of the pipeline and that their dependencies have no cycle
+func (p *Pipeline) Validate() error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("the pipeline has no steps")
+	}
+
+	index := make(map[string]int)
+	for i, step := range p.Steps {
+		if problems := validation.IsDNS1123Label(step.Name); len(problems) > 0 {
+			return fmt.Errorf("invalid step name %q: %s", step.Name, strings.Join(problems, "; "))
+		}
+		if _, exists := index[step.Name]; exists {
+			return fmt.Errorf("there is more than one step named %s", step.Name)
+		}
+		if step.Config == "" {
+			return fmt.Errorf("step %s has no config", step.Name)
+		}
+		index[step.Name] = i
+	}
+
+	dependencies := p.dependencies()
+	for i, step := range p.Steps {
+		for _, dependency := range dependencies[i] {
+			if _, exists := index[dependency]; !exists {
+				return fmt.Errorf("step %s runs after %s, which isn't a step of the pipeline", step.Name, dependency)
+			}
+		}
+	}
+
+	// Depth-first search for a step that depends on itself, directly or not
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(p.Steps))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("step %s depends on itself", p.Steps[i].Name)
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		for _, dependency := range dependencies[i] {
+			if err := visit(index[dependency]); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+	for i := range p.Steps {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeSteps summarizes the steps of a pipeline on one line
+func (p *Pipeline) describeSteps() string {
+	dependencies := p.dependencies()
+	chain := true
+	names := make([]string, len(p.Steps))
+	for i, step := range p.Steps {
+		names[i] = step.Name
+		if (i == 0 && len(dependencies[i]) != 0) || (i > 0 && (len(dependencies[i]) != 1 || dependencies[i][0] != p.Steps[i-1].Name)) {
+			chain = false
+		}
+	}
+	if chain {
+		return strings.Join(names, " → ")
+	}
+
+	for i := range names {
+		if len(dependencies[i]) > 0 {
+			names[i] += " after " + strings.Join(dependencies[i], ", ")
+		}
+	}
+	return strings.Join(names, "; ")
+}
+
+// pipelineRunsPath returns the directory holding the pipeline runs
+func pipelineRunsPath() (string, error) {
+	dir, err := pipelinesPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, pipelineRunsDir), nil
+}
+
+// savePipelineRun writes the state of a run
+func savePipelineRun(run *PipelineRun) error {
+	dir, err := pipelineRunsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create pipeline runs directory: %v", err)
+	}
+
+	data, err := yaml.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline run: %v", err)
+	}
+	// Write and rename, so a crash never leaves a truncated run behind
+	path := filepath.Join(dir, run.ID+".yaml")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write pipeline run: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write pipeline run: %v", err)
+	}
+	return nil
+}
+
+// loadPipelineRun reads the state of a run
+func loadPipelineRun(id string) (*PipelineRun, error) {
+	dir, err := pipelineRunsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline run: %v", err)
+	}
+
+	var run PipelineRun
+	if err := yaml.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline run: %v", err)
+	}
+	run.ID = id
+	return &run, nil
+}
+
+// listPipelineRuns returns the IDs of the pipeline runs, newest first
+func listPipelineRuns() ([]string, error) {
+	dir, err := pipelineRunsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline runs directory: %v", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".yaml" {
+			ids = append(ids, strings.TrimSuffix(entry.Name(), ".yaml"))
+		}
+	}
+	// IDs start with the start time
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// step returns the step of the run with the given name
+func (r *PipelineRun) step(name string) *StepRun {
+	for i := range r.Steps {
+		if r.Steps[i].Name == name {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// dependenciesState reports whether all the steps a step depends on succeeded, and whether
+// any of them won't
+func (r *PipelineRun) dependenciesState(step *StepRun) (ready, blocked bool) {
+	ready = true
+	for _, name := range step.After {
+		dependency := r.step(name)
+		if dependency == nil {
+			continue
+		}
+		switch dependency.Status {
+		case stepSucceeded:
+		case stepFailed, stepSkipped, stepCancelled:
+			return false, true
+		default:
+			ready = false
+		}
+	}
+	return ready, false
+}
+
+// settle finishes the run once no step is pending or running. It returns whether the run is finished.
+func (r *PipelineRun) settle() bool {
+	if r.Status != stepRunning {
+		return true
+	}
+	succeeded := true
+	for _, step := range r.Steps {
+		switch step.Status {
+		case stepPending, stepRunning:
+			return false
+		case stepSucceeded:
+		default:
+			succeeded = false
+		}
+	}
+	r.Status = stepFailed
+	if succeeded {
+		r.Status = stepSucceeded
+	}
+	r.FinishedAt = time.Now()
+	return true
+}
+
+// progress describes how many steps of the run succeeded
+func (r *PipelineRun) progress() string {
+	done := 0
+	for _, step := range r.Steps {
+		if step.Status == stepSucceeded {
+			done++
+		}
+	}
+	return fmt.Sprintf("%d/%d", done, len(r.Steps))
+}
+
+// skipBlocked skips the pending steps that depend on a step that didn't succeed. Skipping a
+// step can block the steps after it, so it repeats until nothing changes. It returns whether
+// any step was skipped.
+func (r *PipelineRun) skipBlocked() bool {
+	changed := false
+	for progress := true; progress; {
+		progress = false
+		for i := range r.Steps {
+			step := &r.Steps[i]
+			if step.Status != stepPending {
+				continue
+			}
+			if _, blocked := r.dependenciesState(step); blocked {
+				step.Status = stepSkipped
+				progress, changed = true, true
+			}
+		}
+	}
+	return changed
+}
+
+// lockPipelineRun takes the lock guarding changes to a run's file. It is a file lock, so it is
+// shared by every KSTool process, and only held while the file is read and written.
+func lockPipelineRun(id string) (unlock func(), err error) {
+	dir, err := pipelineRunsPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create pipeline runs directory: %v", err)
+	}
+	file, err := os.OpenFile(filepath.Join(dir, id+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pipeline run lock: %v", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock pipeline run: %v", err)
+	}
+	return func() { file.Close() }, nil
+}
+
+// updatePipelineRun loads a run and saves it after update, holding the run's lock. update
+// returns whether it changed the run.
+func updatePipelineRun(id string, update func(run *PipelineRun) (bool, error)) (*PipelineRun, error) {
+	unlock, err := lockPipelineRun(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	run, err := loadPipelineRun(id)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := update(run)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		if err := savePipelineRun(run); err != nil {
+			return nil, err
+		}
+	}
+	return run, nil
+}
+
+// acquirePipelineLease makes this process the one executing a run, so two KSTool sessions
+// never submit the same step. The lease is held until the returned file is closed, or the
+// process exits. It returns nil while another process holds the lease.
+func acquirePipelineLease(id string) (*os.File, error) {
+	dir, err := pipelineRunsPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, id+".lease"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pipeline run lease: %v", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock pipeline run lease: %v", err)
+	}
+	return file, nil
+}
+
+// pipelineExecutor advances pipeline runs in the background while KSTool is running
+type pipelineExecutor struct {
+	ctx     context.Context
+	clients *KubeClients
+
+	// mu guards active
+	mu sync.Mutex
+	// active holds the runs being executed by this process
+	active map[string]bool
+}
+
+// executor runs the pipelines, see StartPipelineExecutor
+var executor *pipelineExecutor
+
+// StartPipelineExecutor resumes the pipeline runs left running by an earlier session and
+// executes the runs started from now on
+func StartPipelineExecutor(ctx context.Context, clients *KubeClients) {
+	executor = &pipelineExecutor{ctx: ctx, clients: clients, active: make(map[string]bool)}
+
+	ids, err := listPipelineRuns()
+	if err != nil {
+		LogToSyslog(fmt.Sprintf("Failed to resume pipeline runs: %v", err))
+		return
+	}
+	for _, id := range ids {
+		if run, err := loadPipelineRun(id); err == nil && run.Status == stepRunning {
+			executor.execute(id)
+		}
+	}
+}
+
+// startPipelineRun starts a new run of a pipeline
+func startPipelineRun(name string) (*PipelineRun, error) {
+	if executor == nil {
+		return nil, fmt.Errorf("pipelines can't run without a cluster connection")
+	}
+	pipeline, err := loadPipeline(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range pipeline.Steps {
+		if !configFileExists(step.Config) {
+			return nil, fmt.Errorf("step %s uses configuration %s, which doesn't exist", step.Name, step.Config)
+		}
+	}
+
+	run := &PipelineRun{
+		ID:        time.Now().Format("20060102-150405") + "-" + utilrand.String(4),
+		Pipeline:  name,
+		Status:    stepRunning,
+		StartedAt: time.Now(),
+	}
+	for i, dependencies := range pipeline.dependencies() {
+		step := pipeline.Steps[i]
+		step.After = dependencies
+		run.Steps = append(run.Steps, StepRun{PipelineStep: step, Status: stepPending})
+	}
+	if err := savePipelineRun(run); err != nil {
+		return nil, err
+	}
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Started pipeline %s, run %s",
+		time.Now().Format(time.RFC3339), user, name, run.ID))
+
+	executor.execute(run.ID)
+	return run, nil
+}
+
+// requestContext returns the context of one request to the cluster
+func (e *pipelineExecutor) requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(e.ctx, pipelineRequestTimeout)
+}
+
+// execute advances a run until it is finished, unless this process executes it already.
+// While another process holds the run's lease, it waits to take over if that process exits.
+func (e *pipelineExecutor) execute(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.active[id] {
+		return
+	}
+	e.active[id] = true
+
+	go func() {
+		var lease *os.File
+		defer func() {
+			if lease != nil {
+				lease.Close()
+			}
+		}()
+
+		for {
+			var finished bool
+			var err error
+			if lease == nil {
+				lease, err = acquirePipelineLease(id)
+			}
+			if err == nil && lease != nil {
+				finished, err = e.advance(id)
+			} else if err == nil {
+				var run *PipelineRun
+				if run, err = loadPipelineRun(id); err == nil {
+					finished = run.Status != stepRunning
+				}
+			}
+
+			if err != nil || finished {
+				e.mu.Lock()
+				delete(e.active, id)
+				e.mu.Unlock()
+
+				if err != nil {
+					LogToSyslog(fmt.Sprintf("Pipeline run %s stopped: %v", id, err))
+					return
+				}
+				// A retry between the last check and leaving active would have been left to
+				// this loop, so look once more
+				if run, err := loadPipelineRun(id); err == nil && run.Status == stepRunning {
+					if lease != nil {
+						lease.Close()
+						lease = nil
+					}
+					e.execute(id)
+				}
+				return
+			}
+
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-time.After(pipelinePollInterval):
+			}
+		}
+	}()
+}
+
+// jobResult is the outcome of a running step's job
+type jobResult struct {
+	status  string
+	message string
+}
+
+// stepSubmission is the outcome of submitting a step's job
+type stepSubmission struct {
+	step    string
+	attempt int
+	jobName string
+	err     error
+}
+
+// advance checks the jobs of the running steps of a run and submits the steps whose
+// dependencies succeeded. It returns whether the run is finished. Requests to the cluster are
+// made without holding the run's lock, so the UI never waits for them.
+func (e *pipelineExecutor) advance(id string) (bool, error) {
+	run, err := loadPipelineRun(id)
+	if err != nil {
+		return false, err
+	}
+	if run.Status != stepRunning {
+		return true, nil
+	}
+
+	results := make(map[string]jobResult)
+	for _, step := range run.Steps {
+		if step.Status == stepRunning && step.JobName != "" {
+			if result, ok := e.checkJob(step.JobName); ok {
+				results[step.JobName] = result
+			}
+		}
+	}
+
+	// Record the finished jobs and claim the steps to submit. A step claimed but without a job
+	// was being submitted when KSTool stopped, and is submitted again.
+	var submit []StepRun
+	run, err = updatePipelineRun(id, func(run *PipelineRun) (bool, error) {
+		if run.Status != stepRunning {
+			return false, nil
+		}
+		changed := false
+		for i := range run.Steps {
+			step := &run.Steps[i]
+			result, checked := results[step.JobName]
+			if step.Status != stepRunning || step.JobName == "" || !checked || result.status == stepRunning {
+				continue
+			}
+			step.Status = result.status
+			step.Error = result.message
+			step.FinishedAt = time.Now()
+			changed = true
+		}
+		if run.skipBlocked() {
+			changed = true
+		}
+
+		for i := range run.Steps {
+			step := &run.Steps[i]
+			if step.Status == stepPending {
+				if ready, _ := run.dependenciesState(step); !ready {
+					continue
+				}
+				step.Status = stepRunning
+				step.Attempt++
+				step.StartedAt = time.Now()
+				step.Error = ""
+				changed = true
+			}
+			if step.Status == stepRunning && step.JobName == "" {
+				submit = append(submit, *step)
+			}
+		}
+		if run.settle() {
+			changed = true
+		}
+		return changed, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(submit) == 0 {
+		return run.Status != stepRunning, nil
+	}
+
+	var submissions []stepSubmission
+	for i := range submit {
+		jobName, err := e.submitStepJob(run, &submit[i])
+		submissions = append(submissions, stepSubmission{step: submit[i].Name, attempt: submit[i].Attempt, jobName: jobName, err: err})
+	}
+
+	// Record the submitted jobs; the run may have been cancelled meanwhile, the jobs are kept
+	// on their steps anyway so they can be found
+	run, err = updatePipelineRun(id, func(run *PipelineRun) (bool, error) {
+		for _, submission := range submissions {
+			step := run.step(submission.step)
+			if step == nil || step.Attempt != submission.attempt || step.JobName != "" {
+				continue
+			}
+			step.JobName = submission.jobName
+			var retry *stepRetryError
+			switch {
+			case submission.err == nil:
+				step.Error = ""
+			case step.Status != stepRunning:
+			case errors.As(submission.err, &retry):
+				// Still claimed without a job, so the next poll submits it again
+				step.Error = "retrying: " + submission.err.Error()
+			default:
+				step.Status = stepFailed
+				step.Error = submission.err.Error()
+				step.FinishedAt = time.Now()
+			}
+		}
+		run.skipBlocked()
+		run.settle()
+		return true, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return run.Status != stepRunning, nil
+}
+
+// checkJob returns the outcome of a step's job. ok is false when it couldn't be checked.
+func (e *pipelineExecutor) checkJob(jobName string) (result jobResult, ok bool) {
+	ctx, cancel := e.requestContext()
+	defer cancel()
+
+	job, err := e.clients.Clientset.BatchV1().Jobs(e.clients.Namespace).Get(ctx, jobName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return jobResult{status: stepFailed, message: fmt.Sprintf("job %s was deleted", jobName)}, true
+	}
+	if err != nil {
+		// The cluster may be unreachable for a moment, check again later
+		return jobResult{}, false
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return jobResult{status: stepSucceeded}, true
+		case batchv1.JobFailed:
+			return jobResult{status: stepFailed, message: condition.Message}, true
+		}
+	}
+	return jobResult{status: stepRunning}, true
+}
+
+// submitStepJob creates the job of a step and returns its name. A job already created for
+// this attempt of the step, before KSTool was restarted, is used instead.
+func (e *pipelineExecutor) submitStepJob(run *PipelineRun, step *StepRun) (string, error) {
+	ctx, cancel := e.requestContext()
+	defer cancel()
+
+	labels := map[string]string{
+		pipelineRunLabel:     run.ID,
+		pipelineStepLabel:    step.Name,
+		pipelineAttemptLabel: strconv.Itoa(step.Attempt),
+	}
+	var selector []string
+	for key, value := range labels {
+		selector = append(selector, key+"="+value)
+	}
+	existing, err := e.clients.Clientset.BatchV1().Jobs(e.clients.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: strings.Join(selector, ","),
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to look for the job of the step: %w", err)
+		if isTransientError(err) {
+			// Without knowing whether the job exists the step can't be submitted, try again later
+			return "", &stepRetryError{err: err}
+		}
+		return "", err
+	}
+	if len(existing.Items) > 0 {
+		return existing.Items[0].Name, nil
+	}
+
+	config, err := loadConfig(step.Config)
+	if err != nil {
+		return "", err
+	}
+	// The same checks as a job applied from the configuration list; warnings, which are
+	// confirmed there, can't be here and are only logged
+	if problems := validateManifest(ctx, e.clients, *config); len(problems) > 0 {
+		return "", fmt.Errorf("the job manifest is invalid:\n%s", formatFieldErrors(problems))
+	}
+	manifest, err := renderJobConfig(*config)
+	if err != nil {
+		return "", err
+	}
+	issues, err := LintManifest(manifest)
+	if err != nil {
+		return "", fmt.Errorf("failed to lint job: %v", err)
+	}
+	if hasLintErrors(issues) {
+		return "", fmt.Errorf("the job would not run correctly:\n%s", formatLintIssues(issues))
+	}
+	if len(issues) > 0 {
+		LogToSyslog(fmt.Sprintf("Step %s of pipeline run %s has lint warnings: %s", step.Name, run.ID, formatLintIssues(issues)))
+	}
+
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		return "", err
+	}
+	i, err := findJob(objects)
+	if err != nil {
+		return "", err
+	}
+	jobLabels := objects[i].GetLabels()
+	if jobLabels == nil {
+		jobLabels = make(map[string]string)
+	}
+	for key, value := range labels {
+		jobLabels[key] = value
+	}
+	objects[i].SetLabels(jobLabels)
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Submitting step %s of pipeline %s, run %s, from Config: %v",
+		time.Now().Format(time.RFC3339), user, step.Name, run.Pipeline, run.ID, redactConfig(*config)))
+
+	jobName, err := submitJob(ctx, e.clients, step.Config, *config, objects)
+	if err != nil {
+		if isTransientError(err) {
+			// The job may have been created anyway, the next attempt looks for it first
+			return "", &stepRetryError{err: err}
+		}
+		return "", err
+	}
+	recordConfigApplied(step.Config)
+	return jobName, nil
+}
+
+// stepRetryError is a failure to submit a step that may pass, such as an unreachable
+// cluster. The step stays claimed and is submitted again on the next poll.
+type stepRetryError struct {
+	err error
+}
+
+func (e *stepRetryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stepRetryError) Unwrap() error {
+	return e.err
+}
+
+// isTransientError reports whether a request to the cluster failed for a reason that may
+// pass by itself: no answer at all, a timeout, an overloaded API server, or KSTool exiting
+func isTransientError(err error) bool {
+	var statusErr *apierrors.StatusError
+	if !errors.As(err, &statusErr) {
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+	}
+	return apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err)
+}
+
+// cancel stops a run from submitting more steps. With deleteJobs the jobs of its running
+// steps are deleted too.
+func (e *pipelineExecutor) cancel(id string, deleteJobs bool) error {
+	var jobs []string
+	run, err := updatePipelineRun(id, func(run *PipelineRun) (bool, error) {
+		if run.Status != stepRunning {
+			return false, fmt.Errorf("the run is %s already", run.Status)
+		}
+		for i := range run.Steps {
+			step := &run.Steps[i]
+			switch {
+			case step.Status == stepPending:
+				step.Status = stepCancelled
+			case step.Status == stepRunning && deleteJobs:
+				if step.JobName != "" {
+					jobs = append(jobs, step.JobName)
+				}
+				step.Status = stepCancelled
+				step.FinishedAt = time.Now()
+			}
+		}
+		run.Status = stepCancelled
+		run.FinishedAt = time.Now()
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Cancelled pipeline %s, run %s",
+		time.Now().Format(time.RFC3339), user, run.Pipeline, run.ID))
+
+	var problems []string
+	for _, job := range jobs {
+		ctx, cancel := e.requestContext()
+		propagation := metav1.DeletePropagationBackground
+		err := e.clients.Clientset.BatchV1().Jobs(e.clients.Namespace).Delete(ctx, job, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		cancel()
+		if err != nil && !apierrors.IsNotFound(err) {
+			problems = append(problems, fmt.Sprintf("failed to delete job %s: %v", job, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the run was cancelled, but:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// retry runs the failed, skipped and cancelled steps of a finished run again
+func (e *pipelineExecutor) retry(id string) error {
+	run, err := updatePipelineRun(id, func(run *PipelineRun) (bool, error) {
+		if run.Status == stepRunning || run.Status == stepSucceeded {
+			return false, fmt.Errorf("only failed or cancelled runs can be retried")
+		}
+		for i := range run.Steps {
+			step := &run.Steps[i]
+			switch step.Status {
+			case stepFailed, stepSkipped, stepCancelled:
+				step.Status = stepPending
+				step.JobName = ""
+				step.Error = ""
+				step.StartedAt = time.Time{}
+				step.FinishedAt = time.Time{}
+			}
+		}
+		run.Status = stepRunning
+		run.FinishedAt = time.Time{}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Retried pipeline %s, run %s",
+		time.Now().Format(time.RFC3339), user, run.Pipeline, run.ID))
+
+	e.execute(id)
+	return nil
+}
+
+// renamePipelineConfig points the pipeline steps using a renamed configuration to its new
+// name, in the pipeline definitions and in their runs so they can still be retried
+func renamePipelineConfig(oldName, newName string) error {
+	names, err := listPipelines()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := renameConfigInPipeline(name, oldName, newName); err != nil {
+			return fmt.Errorf("failed to update pipeline %s: %v", name, err)
+		}
+	}
+
+	ids, err := listPipelineRuns()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		_, err := updatePipelineRun(id, func(run *PipelineRun) (bool, error) {
+			changed := false
+			for i := range run.Steps {
+				if run.Steps[i].Config == oldName {
+					run.Steps[i].Config = newName
+					changed = true
+				}
+			}
+			return changed, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update pipeline run %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// renameConfigInPipeline replaces a configuration name in the steps of a pipeline definition.
+// The definition is edited as a YAML node tree so the comments written in it are kept.
+func renameConfigInPipeline(name, oldName, newName string) error {
+	path, err := pipelinePath(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read pipeline: %v", err)
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("failed to parse pipeline: %v", err)
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	changed := false
+	root := document.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "steps" {
+			continue
+		}
+		for _, step := range root.Content[i+1].Content {
+			for j := 0; j+1 < len(step.Content); j += 2 {
+				if step.Content[j].Value == "config" && step.Content[j+1].Value == oldName {
+					step.Content[j+1].Value = newName
+					changed = true
+				}
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return fmt.Errorf("failed to marshal pipeline: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write pipeline: %v", err)
+	}
+	return nil
+}
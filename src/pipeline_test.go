@@ -0,0 +1,261 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// startTestRun saves a running run of one step using the given configuration
+func startTestRun(t *testing.T, configName string) *PipelineRun {
+	t.Helper()
+	run := &PipelineRun{ID: "run-1", Pipeline: "train", Status: stepRunning, Steps: []StepRun{
+		{PipelineStep: PipelineStep{Name: "train", Config: configName}, Status: stepPending},
+	}}
+	if err := savePipelineRun(run); err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+func TestPipelineStepRetriedAfterTransientError(t *testing.T) {
+	setupConfigs(t)
+	clients := newFakeKubeClients()
+	executor := &pipelineExecutor{ctx: context.Background(), clients: clients, active: make(map[string]bool)}
+	startTestRun(t, "missing")
+
+	// The job was created before the cluster stopped answering
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "train-abc", Namespace: clients.Namespace, Labels: map[string]string{
+		pipelineRunLabel: "run-1", pipelineStepLabel: "train", pipelineAttemptLabel: "1",
+	}}}
+	clientset := fake.NewSimpleClientset(job)
+	unavailable := true
+	clientset.PrependReactor("list", "jobs", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if unavailable {
+			return true, nil, apierrors.NewServiceUnavailable("overloaded")
+		}
+		return false, nil, nil
+	})
+	clients.Clientset = clientset
+
+	if _, err := executor.advance("run-1"); err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	run, _ := loadPipelineRun("run-1")
+	if step := run.Steps[0]; step.Status != stepRunning || step.JobName != "" || !strings.Contains(step.Error, "retrying") {
+		t.Fatalf("after a transient error the step is %s with job %q and error %q, want it still claimed", step.Status, step.JobName, step.Error)
+	}
+
+	unavailable = false
+	if _, err := executor.advance("run-1"); err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	run, _ = loadPipelineRun("run-1")
+	if step := run.Steps[0]; step.Status != stepRunning || step.JobName != "train-abc" || step.Attempt != 1 || step.Error != "" {
+		t.Errorf("step is %s with job %q, attempt %d and error %q, want the existing job of attempt 1", step.Status, step.JobName, step.Attempt, step.Error)
+	}
+}
+
+func TestPipelineStepFailsLint(t *testing.T) {
+	setupConfigs(t)
+	home, _ := os.UserHomeDir()
+	templates := filepath.Join(home, configDir, templatesDir)
+	if err := os.MkdirAll(templates, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The queue label is missing, so Kueue would never admit the job
+	template := `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: train
+  labels:
+    eidf/user: ${USER_NAME:-alice}
+spec:
+  template:
+    spec:
+      containers:
+        - name: main
+          image: ubuntu:22.04
+      restartPolicy: Never
+`
+	if err := os.WriteFile(filepath.Join(templates, "no-queue.yaml"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mustWriteConfig(t, "train", &Config{Template: "no-queue"})
+
+	clients := newFakeKubeClients()
+	executor := &pipelineExecutor{ctx: context.Background(), clients: clients, active: make(map[string]bool)}
+	startTestRun(t, "train")
+
+	finished, err := executor.advance("run-1")
+	if err != nil {
+		t.Fatalf("advance: %v", err)
+	}
+	run, _ := loadPipelineRun("run-1")
+	if step := run.Steps[0]; !finished || step.Status != stepFailed || !strings.Contains(step.Error, kueueQueueLabel) {
+		t.Errorf("step is %s with error %q, want it failed by the queue label lint", step.Status, step.Error)
+	}
+}
+
+func TestPipelineDependencies(t *testing.T) {
+	pipeline := Pipeline{Steps: []PipelineStep{
+		{Name: "prep", Config: "prep"},
+		{Name: "train", Config: "train"},
+		{Name: "eval", Config: "eval"},
+	}}
+	want := [][]string{{}, {"prep"}, {"train"}}
+	if got := pipeline.dependencies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+	if got := pipeline.describeSteps(); got != "prep → train → eval" {
+		t.Errorf("describeSteps = %q", got)
+	}
+
+	pipeline.Steps[1].After = []string{}
+	pipeline.Steps = append(pipeline.Steps, PipelineStep{Name: "report", Config: "report", After: []string{"train", "eval"}})
+	want = [][]string{{}, {}, {"train"}, {"train", "eval"}}
+	if got := pipeline.dependencies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencies = %v, want %v", got, want)
+	}
+	if got := pipeline.describeSteps(); got != "prep; train; eval after train; report after train, eval" {
+		t.Errorf("describeSteps = %q", got)
+	}
+	if err := pipeline.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestPipelineValidate(t *testing.T) {
+	step := func(name string, after ...string) PipelineStep {
+		return PipelineStep{Name: name, Config: "train", After: after}
+	}
+	tests := []struct {
+		name  string
+		steps []PipelineStep
+		err   string
+	}{
+		{name: "no steps", err: "no steps"},
+		{name: "invalid name", steps: []PipelineStep{step("Train")}, err: "invalid step name"},
+		{name: "duplicate name", steps: []PipelineStep{step("a"), step("a")}, err: "more than one step named a"},
+		{name: "no config", steps: []PipelineStep{{Name: "a"}}, err: "has no config"},
+		{name: "unknown step", steps: []PipelineStep{step("a", "b")}, err: "runs after b, which isn't a step"},
+		{name: "itself", steps: []PipelineStep{step("a", "a")}, err: "depends on itself"},
+		{name: "cycle", steps: []PipelineStep{step("a", "c"), step("b"), step("c")}, err: "depends on itself"},
+	}
+	for _, tt := range tests {
+		err := (&Pipeline{Steps: tt.steps}).Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: Validate = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestPipelineRunSkipBlocked(t *testing.T) {
+	run := &PipelineRun{Status: stepRunning, Steps: []StepRun{
+		{PipelineStep: PipelineStep{Name: "a", After: []string{}}, Status: stepFailed},
+		{PipelineStep: PipelineStep{Name: "b", After: []string{"a"}}, Status: stepPending},
+		{PipelineStep: PipelineStep{Name: "c", After: []string{"b"}}, Status: stepPending},
+		{PipelineStep: PipelineStep{Name: "d", After: []string{}}, Status: stepRunning},
+	}}
+
+	if !run.skipBlocked() || run.Steps[1].Status != stepSkipped || run.Steps[2].Status != stepSkipped {
+		t.Fatalf("steps after a failed step weren't skipped: %+v", run.Steps)
+	}
+	if run.settle() {
+		t.Fatalf("the run settled while d is running")
+	}
+	run.Steps[3].Status = stepSucceeded
+	if !run.settle() || run.Status != stepFailed || run.progress() != "1/4" {
+		t.Errorf("run is %s with %s steps done, want failed with 1/4", run.Status, run.progress())
+	}
+}
+
+func TestPipelineRunFollowsDependencies(t *testing.T) {
+	setupConfigs(t)
+	clients := newFakeKubeClients()
+	executor := &pipelineExecutor{ctx: context.Background(), clients: clients, active: make(map[string]bool)}
+	run := &PipelineRun{ID: "run-1", Pipeline: "train", Status: stepRunning, Steps: []StepRun{
+		{PipelineStep: PipelineStep{Name: "prep", Config: "prep", After: []string{}}, Status: stepPending},
+		{PipelineStep: PipelineStep{Name: "train", Config: "train", After: []string{"prep"}}, Status: stepPending},
+	}}
+	if err := savePipelineRun(run); err != nil {
+		t.Fatal(err)
+	}
+
+	// The jobs exist already, so the steps pick them up without rendering their configurations
+	jobs := clients.Clientset.BatchV1().Jobs(clients.Namespace)
+	for _, name := range []string{"prep", "train"} {
+		job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name + "-job", Labels: map[string]string{
+			pipelineRunLabel: "run-1", pipelineStepLabel: name, pipelineAttemptLabel: "1",
+		}}}
+		if _, err := jobs.Create(context.Background(), job, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	complete := func(name string) {
+		job, _ := jobs.Get(context.Background(), name, metav1.GetOptions{})
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		if _, err := jobs.Update(context.Background(), job, metav1.UpdateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	statuses := func() string {
+		run, _ := loadPipelineRun("run-1")
+		return fmt.Sprintf("%s: %s %s", run.Status, run.Steps[0].Status, run.Steps[1].Status)
+	}
+
+	for _, want := range []string{
+		"running: running pending",
+		"running: succeeded running",
+		"succeeded: succeeded succeeded",
+	} {
+		if _, err := executor.advance("run-1"); err != nil {
+			t.Fatalf("advance: %v", err)
+		}
+		if got := statuses(); got != want {
+			t.Fatalf("run is %q, want %q", got, want)
+		}
+		switch want {
+		case "running: running pending":
+			complete("prep-job")
+		case "running: succeeded running":
+			complete("train-job")
+		}
+	}
+}
+
+func TestRenamePipelineConfig(t *testing.T) {
+	setupConfigs(t)
+	dir, _ := pipelinesPath()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	definition := "# Nightly training\nsteps:\n  - name: prep\n    config: prep # shared\n  - name: train\n    config: train-old\n    after: [prep]\n"
+	if err := os.WriteFile(filepath.Join(dir, "nightly.yaml"), []byte(definition), 0644); err != nil {
+		t.Fatal(err)
+	}
+	startTestRun(t, "train-old")
+
+	if err := renamePipelineConfig("train-old", "train"); err != nil {
+		t.Fatalf("renamePipelineConfig: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "nightly.yaml"))
+	if want := strings.Replace(definition, "train-old", "train", 1); string(data) != want {
+		t.Errorf("pipeline definition is\n%s\nwant\n%s", data, want)
+	}
+	if run, _ := loadPipelineRun("run-1"); run.Steps[0].Config != "train" {
+		t.Errorf("the run's step uses config %q, want train", run.Steps[0].Config)
+	}
+}
@@ -0,0 +1,492 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// pipelineTemplate is the starting point of a new pipeline definition
+const pipelineTemplate = `# Pipeline %s
+#
+# Each step submits a saved configuration as a job once the steps listed in "after" have
+# succeeded. A step without "after" waits for the step before it, "after: []" starts it
+# right away. Step names may use lowercase letters, digits and dashes.
+#
+# Saved configurations: %s
+description: ""
+steps:
+  - name: preprocess
+    config: CHANGE-ME
+  - name: train
+    config: CHANGE-ME
+  - name: evaluate
+    config: CHANGE-ME
+`
+
+// stepStatusColors are the colors of the run and step states
+var stepStatusColors = map[string]tcell.Color{
+	stepPending:   tcell.ColorGray,
+	stepRunning:   tcell.ColorYellow,
+	stepSucceeded: tcell.ColorGreen,
+	stepFailed:    tcell.ColorRed,
+	stepSkipped:   tcell.ColorGray,
+	stepCancelled: tcell.ColorGray,
+}
+
+// formatPipelineTime formats a time of a run, or "-" if it didn't happen yet
+func formatPipelineTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("01-02 15:04:05")
+}
+
+// newPipelineFile writes the template of a new pipeline and returns its path
+func newPipelineFile(name string) (string, error) {
+	if !validBundleName(name) {
+		return "", fmt.Errorf("invalid pipeline name %q", name)
+	}
+	path, err := pipelinePath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("pipeline %s already exists", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create pipelines directory: %v", err)
+	}
+
+	configs, _ := loadConfigList()
+	list := strings.Join(configs, ", ")
+	if list == "" {
+		list = "none yet"
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(pipelineTemplate, name, list)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write pipeline: %v", err)
+	}
+	return path, nil
+}
+
+// checkPipeline reports the problems of a pipeline definition after it was edited
+func checkPipeline(name string) error {
+	pipeline, err := loadPipeline(name)
+	if err != nil {
+		return err
+	}
+	for _, step := range pipeline.Steps {
+		if !configFileExists(step.Config) {
+			return fmt.Errorf("step %s uses configuration %s, which doesn't exist", step.Name, step.Config)
+		}
+	}
+	return nil
+}
+
+// ShowPipelines lists the pipeline definitions and their runs. Pipelines can be created,
+// edited and started here, and the steps of a run followed, cancelled and retried.
+// onClose is called when the view is left.
+func ShowPipelines(app *tview.Application, onClose func()) {
+	pipelines := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	pipelines.SetBorder(true).SetTitle("Pipelines").SetTitleAlign(tview.AlignLeft)
+	runs := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	runs.SetBorder(true).SetTitle("Runs").SetTitleAlign(tview.AlignLeft)
+	help := tview.NewTextView().SetTextAlign(tview.AlignCenter)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(pipelines, 0, 1, true).
+		AddItem(runs, 0, 1, false).
+		AddItem(help, 1, 0, false)
+
+	const pipelinesHelp = "Enter - Start run | n - New | e - Edit | d - Delete | r - Refresh | Tab - Runs | Esc - Back"
+	const runsHelp = "Enter - Show steps | r - Refresh | Tab - Pipelines | Esc - Back"
+	help.SetText(pipelinesHelp)
+
+	var names, runIDs []string
+
+	refresh := func() error {
+		var err error
+		if names, err = listPipelines(); err != nil {
+			return err
+		}
+		if runIDs, err = listPipelineRuns(); err != nil {
+			return err
+		}
+
+		pipelines.Clear()
+		for i, header := range []string{"NAME", "STEPS", "DESCRIPTION"} {
+			pipelines.SetCell(0, i, tview.NewTableCell(header).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+		}
+		for i, name := range names {
+			steps, description := "", ""
+			pipeline, err := loadPipeline(name)
+			if err != nil {
+				steps = "invalid: " + err.Error()
+			} else {
+				steps, description = pipeline.describeSteps(), pipeline.Description
+			}
+			pipelines.SetCell(i+1, 0, tview.NewTableCell(name))
+			pipelines.SetCell(i+1, 1, tview.NewTableCell(steps))
+			pipelines.SetCell(i+1, 2, tview.NewTableCell(description))
+		}
+
+		runs.Clear()
+		for i, header := range []string{"RUN", "PIPELINE", "STATUS", "STEPS DONE", "STARTED", "FINISHED"} {
+			runs.SetCell(0, i, tview.NewTableCell(header).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+		}
+		for i, id := range runIDs {
+			run, err := loadPipelineRun(id)
+			if err != nil {
+				runs.SetCell(i+1, 0, tview.NewTableCell(id))
+				runs.SetCell(i+1, 2, tview.NewTableCell("invalid").SetTextColor(tcell.ColorRed))
+				continue
+			}
+			runs.SetCell(i+1, 0, tview.NewTableCell(id))
+			runs.SetCell(i+1, 1, tview.NewTableCell(run.Pipeline))
+			runs.SetCell(i+1, 2, tview.NewTableCell(run.Status).SetTextColor(stepStatusColors[run.Status]))
+			runs.SetCell(i+1, 3, tview.NewTableCell(run.progress()))
+			runs.SetCell(i+1, 4, tview.NewTableCell(formatPipelineTime(run.StartedAt)))
+			runs.SetCell(i+1, 5, tview.NewTableCell(formatPipelineTime(run.FinishedAt)))
+		}
+		return nil
+	}
+
+	if err := refresh(); err != nil {
+		modal := tview.NewModal().
+			SetText(err.Error()).
+			AddButtons([]string{"OK"}).
+			SetDoneFunc(func(int, string) { onClose() })
+		app.SetRoot(modal, true)
+		return
+	}
+
+	back := func() {
+		if err := refresh(); err != nil {
+			showError(app, layout, err.Error())
+			return
+		}
+		help.SetText(pipelinesHelp)
+		app.SetRoot(layout, true)
+	}
+
+	// selectedPipeline returns the name of the selected pipeline definition
+	selectedPipeline := func() string {
+		row, _ := pipelines.GetSelection()
+		if row < 1 || row > len(names) {
+			return ""
+		}
+		return names[row-1]
+	}
+
+	// edit opens a pipeline definition in the editor and reports its problems afterwards
+	edit := func(name, path string) {
+		if err := EditFile(app, path, false); err != nil {
+			showError(app, layout, err.Error())
+			return
+		}
+		if err := refresh(); err != nil {
+			showError(app, layout, err.Error())
+			return
+		}
+		if err := checkPipeline(name); err != nil {
+			showError(app, layout, fmt.Sprintf("Pipeline %s was saved but can't run yet: %v", name, err))
+		}
+	}
+
+	newPipeline := func() {
+		form := tview.NewForm()
+		form.AddInputField("Name", "", 40, nil, nil)
+		form.AddButton("Create", func() {
+			name := strings.TrimSpace(form.GetFormItemByLabel("Name").(*tview.InputField).GetText())
+			path, err := newPipelineFile(name)
+			if err != nil {
+				showError(app, form, err.Error())
+				return
+			}
+			app.SetRoot(layout, true)
+			edit(name, path)
+		})
+		form.AddButton("Cancel", func() {
+			app.SetRoot(layout, true)
+		})
+		form.SetBorder(true).SetTitle("New Pipeline").SetTitleAlign(tview.AlignLeft)
+		app.SetRoot(form, true)
+	}
+
+	pipelines.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			onClose()
+			return nil
+		case tcell.KeyTab:
+			help.SetText(runsHelp)
+			app.SetFocus(runs)
+			return nil
+		case tcell.KeyEnter:
+			name := selectedPipeline()
+			if name == "" {
+				return nil
+			}
+			pipeline, err := loadPipeline(name)
+			if err != nil {
+				showError(app, layout, err.Error())
+				return nil
+			}
+			modal := tview.NewModal().
+				SetText(fmt.Sprintf("Start pipeline %s?\n\n%s", name, pipeline.describeSteps())).
+				AddButtons([]string{"Cancel", "Start"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					if buttonLabel != "Start" {
+						app.SetRoot(layout, true)
+						return
+					}
+					run, err := startPipelineRun(name)
+					if err != nil {
+						showError(app, layout, err.Error())
+						return
+					}
+					showPipelineRun(app, run.ID, back)
+				})
+			app.SetRoot(modal, true)
+			return nil
+		case tcell.KeyRune:
+		default:
+			return event
+		}
+
+		switch event.Rune() {
+		case 'r':
+			if err := refresh(); err != nil {
+				showError(app, layout, err.Error())
+			}
+		case 'n':
+			newPipeline()
+		case 'e':
+			if name := selectedPipeline(); name != "" {
+				if path, err := pipelinePath(name); err != nil {
+					showError(app, layout, err.Error())
+				} else {
+					edit(name, path)
+				}
+			}
+		case 'd':
+			name := selectedPipeline()
+			if name == "" {
+				return nil
+			}
+			modal := tview.NewModal().
+				SetText(fmt.Sprintf("Delete pipeline %s? Its runs are kept.", name)).
+				AddButtons([]string{"Cancel", "Delete"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					if buttonLabel != "Delete" {
+						app.SetRoot(layout, true)
+						return
+					}
+					path, err := pipelinePath(name)
+					if err == nil {
+						err = os.Remove(path)
+					}
+					if err != nil {
+						showError(app, layout, fmt.Sprintf("failed to delete pipeline: %v", err))
+						return
+					}
+					back()
+				})
+			app.SetRoot(modal, true)
+		default:
+			return event
+		}
+		return nil
+	})
+
+	runs.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			onClose()
+			return nil
+		case tcell.KeyTab:
+			help.SetText(pipelinesHelp)
+			app.SetFocus(pipelines)
+			return nil
+		case tcell.KeyEnter:
+			row, _ := runs.GetSelection()
+			if row >= 1 && row <= len(runIDs) {
+				showPipelineRun(app, runIDs[row-1], back)
+			}
+			return nil
+		case tcell.KeyRune:
+			if event.Rune() == 'r' {
+				if err := refresh(); err != nil {
+					showError(app, layout, err.Error())
+				}
+				return nil
+			}
+		}
+		return event
+	})
+
+	app.SetRoot(layout, true)
+}
+
+// showPipelineRun shows the steps of a pipeline run with their jobs. The run can be cancelled
+// or, once finished, its failed steps retried.
+func showPipelineRun(app *tview.Application, id string, onClose func()) {
+	table := tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	table.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+	details := tview.NewTextView().SetWrap(true)
+	details.SetBorder(true).SetTitle("Step")
+	help := tview.NewTextView().
+		SetText("r - Refresh | c - Cancel run | t - Retry failed steps | Esc - Back").
+		SetTextAlign(tview.AlignCenter)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(table, 0, 2, true).
+		AddItem(details, 0, 1, false).
+		AddItem(help, 1, 0, false)
+
+	var run *PipelineRun
+
+	showDetails := func(row int) {
+		if run == nil || row < 1 || row > len(run.Steps) {
+			details.SetText("")
+			return
+		}
+		step := run.Steps[row-1]
+		var lines []string
+		lines = append(lines, fmt.Sprintf("Step: %s (config %s)", step.Name, step.Config))
+		if len(step.After) > 0 {
+			lines = append(lines, "After: "+strings.Join(step.After, ", "))
+		}
+		lines = append(lines, "Status: "+step.Status)
+		if step.JobName != "" {
+			lines = append(lines, fmt.Sprintf("Job: %s (attempt %d)", step.JobName, step.Attempt))
+		}
+		if step.Error != "" {
+			lines = append(lines, "Error: "+step.Error)
+		}
+		details.SetText(strings.Join(lines, "\n"))
+	}
+	table.SetSelectionChangedFunc(func(row, column int) {
+		showDetails(row)
+	})
+
+	refresh := func() error {
+		var err error
+		if run, err = loadPipelineRun(id); err != nil {
+			return err
+		}
+
+		table.Clear()
+		for i, header := range []string{"STEP", "CONFIG", "AFTER", "STATUS", "ATTEMPT", "JOB", "STARTED", "FINISHED"} {
+			table.SetCell(0, i, tview.NewTableCell(header).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+		}
+		for i, step := range run.Steps {
+			after, job, attempt := "-", "-", "-"
+			if len(step.After) > 0 {
+				after = strings.Join(step.After, ", ")
+			}
+			if step.JobName != "" {
+				job = step.JobName
+			}
+			if step.Attempt > 0 {
+				attempt = strconv.Itoa(step.Attempt)
+			}
+			table.SetCell(i+1, 0, tview.NewTableCell(step.Name))
+			table.SetCell(i+1, 1, tview.NewTableCell(step.Config))
+			table.SetCell(i+1, 2, tview.NewTableCell(after))
+			table.SetCell(i+1, 3, tview.NewTableCell(step.Status).SetTextColor(stepStatusColors[step.Status]))
+			table.SetCell(i+1, 4, tview.NewTableCell(attempt))
+			table.SetCell(i+1, 5, tview.NewTableCell(job))
+			table.SetCell(i+1, 6, tview.NewTableCell(formatPipelineTime(step.StartedAt)))
+			table.SetCell(i+1, 7, tview.NewTableCell(formatPipelineTime(step.FinishedAt)))
+		}
+		table.SetTitle(fmt.Sprintf("Pipeline %s, run %s: %s (%s steps done)", run.Pipeline, run.ID, run.Status, run.progress()))
+		row, _ := table.GetSelection()
+		showDetails(row)
+		return nil
+	}
+
+	if err := refresh(); err != nil {
+		modal := tview.NewModal().
+			SetText(err.Error()).
+			AddButtons([]string{"OK"}).
+			SetDoneFunc(func(int, string) { onClose() })
+		app.SetRoot(modal, true)
+		return
+	}
+
+	// done refreshes the run after an action and reports its outcome
+	done := func(message string, err error) {
+		if refreshErr := refresh(); err == nil {
+			err = refreshErr
+		}
+		if err != nil {
+			showError(app, layout, err.Error())
+			return
+		}
+		showMessage(app, layout, message)
+	}
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			onClose()
+			return nil
+		}
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+
+		switch event.Rune() {
+		case 'r':
+			if err := refresh(); err != nil {
+				showError(app, layout, err.Error())
+			}
+		case 'c':
+			if executor == nil {
+				showError(app, layout, "pipelines can't run without a cluster connection")
+				return nil
+			}
+			if run.Status != stepRunning {
+				showError(app, layout, fmt.Sprintf("The run is %s already", run.Status))
+				return nil
+			}
+			modal := tview.NewModal().
+				SetText("Cancel this run? No more steps will be submitted. Jobs already running keep running unless deleted too.").
+				AddButtons([]string{"Back", "Cancel Run", "Cancel Run and Delete Jobs"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					if buttonLabel == "Back" || buttonLabel == "" {
+						app.SetRoot(layout, true)
+						return
+					}
+					deleteJobs := buttonLabel == "Cancel Run and Delete Jobs"
+					done("Run cancelled", executor.cancel(id, deleteJobs))
+				})
+			app.SetRoot(modal, true)
+		case 't':
+			if executor == nil {
+				showError(app, layout, "pipelines can't run without a cluster connection")
+				return nil
+			}
+			modal := tview.NewModal().
+				SetText("Run the failed, skipped and cancelled steps of this run again?").
+				AddButtons([]string{"Cancel", "Retry"}).
+				SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+					if buttonLabel != "Retry" {
+						app.SetRoot(layout, true)
+						return
+					}
+					done("Run restarted", executor.retry(id))
+				})
+			app.SetRoot(modal, true)
+		default:
+			return event
+		}
+		return nil
+	})
+
+	app.SetRoot(layout, true)
+}
@@ -0,0 +1,138 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// Sources of the value substituted for a placeholder in the preview
+const (
+	valueUnset = iota
+	valueFromConfig
+	valueFromDefault
+)
+
+// manifestPreview is a manifest rendered with tview color tags marking what the template
+// substituted: values of the configuration, template defaults and unresolved variables
+type manifestPreview struct {
+	Text string
+	// Unresolved lists the variables left without a value, in order of occurrence
+	Unresolved []string
+	// Lines maps each variable to the rendered line of its first occurrence, starting at 0
+	Lines map[string]int
+
+	template *Template
+	// raw is the rendered text without color tags
+	raw  strings.Builder
+	line int
+}
+
+// renderPreview renders a parsed template with the configuration values for display.
+// Unlike renderTemplate it doesn't stop at required variables without a value, and it
+// masks the values of secret variables.
+func renderPreview(template *Template, config Config, specs map[string]*VarSpec) *manifestPreview {
+	defaults := make(map[string]string)
+	for _, variable := range template.Variables() {
+		defaults[variable.Name] = personalizeDefault(variable.Default)
+	}
+
+	lookup := func(name string) (string, int) {
+		if value, ok := config.GetEnvVar(name); ok {
+			if value != "" && varSpecFor(specs, name).Secret {
+				value = redactedValue
+			}
+			return value, valueFromConfig
+		}
+		if value := defaults[name]; value != "" {
+			return value, valueFromDefault
+		}
+		return "", valueUnset
+	}
+
+	preview := &manifestPreview{Lines: make(map[string]int), template: template}
+	var text strings.Builder
+	preview.writeSegments(&text, template.segments, lookup)
+	preview.Text = text.String()
+	return preview
+}
+
+// write adds text to the preview in the given color, or uncolored when color is empty
+func (p *manifestPreview) write(text *strings.Builder, s, color string) {
+	p.line += strings.Count(s, "\n")
+	p.raw.WriteString(s)
+	if color == "" {
+		text.WriteString(tview.Escape(s))
+		return
+	}
+	// Color each line on its own so multi-line values keep their color when scrolled
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+		if line != "" {
+			fmt.Fprintf(text, "[%s]%s[-]", color, tview.Escape(line))
+		}
+	}
+}
+
+// writeSegments follows the rules of renderSegments, marking what each placeholder became
+func (p *manifestPreview) writeSegments(text *strings.Builder, segments []templateSegment, lookup func(name string) (string, int)) {
+	for _, segment := range segments {
+		if segment.placeholder == nil {
+			p.write(text, segment.literal, "")
+			continue
+		}
+
+		placeholder := segment.placeholder
+		if _, seen := p.Lines[placeholder.Name]; !seen {
+			p.Lines[placeholder.Name] = p.line
+		}
+
+		value, source := lookup(placeholder.Name)
+		set := source != valueUnset
+		nonEmpty := set && value != ""
+		color := "yellow"
+		if source == valueFromDefault {
+			color = "darkcyan"
+		}
+
+		switch placeholder.Op {
+		case opValue:
+			if set {
+				p.writeValue(text, placeholder, value, color)
+			} else {
+				p.unresolved(text, placeholder)
+			}
+		case opDefault, opDefaultUnset:
+			if nonEmpty || (set && placeholder.Op == opDefaultUnset) {
+				p.writeValue(text, placeholder, value, color)
+			} else {
+				p.writeSegments(text, placeholder.arg, lookup)
+			}
+		case opRequired, opRequiredUnset:
+			if nonEmpty || (set && placeholder.Op == opRequiredUnset) {
+				p.writeValue(text, placeholder, value, color)
+			} else {
+				p.unresolved(text, placeholder)
+			}
+		case opAlternate, opAlternateUnset:
+			if nonEmpty || (set && placeholder.Op == opAlternateUnset) {
+				p.writeSegments(text, placeholder.arg, lookup)
+			}
+		}
+	}
+}
+
+// writeValue adds the value substituted for a placeholder, formatted as renderTemplate does
+func (p *manifestPreview) writeValue(text *strings.Builder, placeholder *Placeholder, value, color string) {
+	p.write(text, formatValue([]byte(p.raw.String()), p.template.restOfLine(placeholder), value), color)
+}
+
+// unresolved marks a placeholder that has no value
+func (p *manifestPreview) unresolved(text *strings.Builder, placeholder *Placeholder) {
+	p.Unresolved = append(p.Unresolved, placeholder.Name)
+	p.raw.WriteString("${" + placeholder.Name + "}")
+	fmt.Fprintf(text, "[white:red]%s[-:-]", tview.Escape("${"+placeholder.Name+"}"))
+}
@@ -0,0 +1,50 @@
+package src
+
+import (
+	"os"
+	"strings"
+)
+
+// personalizeDefault replaces the "default-user" marker of template defaults with the current user
+func personalizeDefault(value string) string {
+	return strings.ReplaceAll(value, "default-user", os.Getenv("USER"))
+}
+
+// renderTemplate substitutes the declared template variables with the config values.
+// Variables missing from the config fall back to their template defaults.
+func renderTemplate(content []byte, config Config) ([]byte, error) {
+	template, err := ParseTemplate(content)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := make(map[string]string)
+	for _, variable := range template.Variables() {
+		defaults[variable.Name] = personalizeDefault(variable.Default)
+	}
+
+	return template.Render(func(name string) (string, bool) {
+		if value, ok := config.GetEnvVar(name); ok {
+			return value, true
+		}
+		// Unset variables without a default keep shell semantics, e.g. ${VAR:?message} fails
+		if value := defaults[name]; value != "" {
+			return value, true
+		}
+		return "", false
+	})
+}
+
+// renderJobConfig renders the configuration's template with its values and applies its overlay
+func renderJobConfig(config Config) ([]byte, error) {
+	content, err := readTemplate(config.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := renderTemplate(content, config)
+	if err != nil {
+		return nil, err
+	}
+	return applyOverlay(manifest, config.Overlay)
+}
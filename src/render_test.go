@@ -0,0 +1,73 @@
+package src
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	template := []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: ${JOB_NAME:-default-user-job}-
+spec:
+  template:
+    spec:
+      containers:
+        - name: main
+          image: ${IMAGE:-ubuntu:22.04}
+          command: ["bash", "-c", "cd $HOME/data && ls $${HOME} && echo ${GPU_NUM:-1}"]
+`)
+	config := Config{EnvVars: []EnvVar{{Key: "IMAGE", Value: "pytorch:2.1"}}}
+	t.Setenv("USER", "alice")
+
+	manifest, err := renderTemplate(template, config)
+	if err != nil {
+		t.Fatalf("renderTemplate: %v", err)
+	}
+	rendered := string(manifest)
+
+	for _, want := range []string{
+		"generateName: alice-job-",
+		"image: pytorch:2.1",
+		// Bare $HOME isn't a template variable, so it is left for the container's shell
+		"cd $HOME/data",
+		// $${ is an escaped placeholder
+		"ls ${HOME}",
+		"echo 1",
+	} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("rendered manifest doesn't contain %q:\n%s", want, rendered)
+		}
+	}
+}
+
+func TestRenderTemplateShellVariables(t *testing.T) {
+	template := []byte(`# kstool: LR type=float
+args: ["cd ${HOME}/x && echo ${JOB_COMPLETION_INDEX} --lr=${LR}"]
+`)
+	manifest, err := renderTemplate(template, Config{EnvVars: []EnvVar{{Key: "LR", Value: "0.1"}}})
+	if err != nil {
+		t.Fatalf("renderTemplate: %v", err)
+	}
+	want := `args: ["cd ${HOME}/x && echo ${JOB_COMPLETION_INDEX} --lr=0.1"]`
+	if !strings.Contains(string(manifest), want) {
+		t.Errorf("rendered manifest doesn't contain %q:\n%s", want, manifest)
+	}
+}
+
+func TestRenderTemplateRequired(t *testing.T) {
+	_, err := renderTemplate([]byte("name: ${TASK_SCRIPT:?a script is needed}\n"), Config{})
+	if err == nil || !strings.Contains(err.Error(), "a script is needed") {
+		t.Fatalf("expected the required variable's message, got %v", err)
+	}
+
+	manifest, err := renderTemplate([]byte("name: ${TASK_SCRIPT:?a script is needed}\n"),
+		Config{EnvVars: []EnvVar{{Key: "TASK_SCRIPT", Value: "train.sh"}}})
+	if err != nil {
+		t.Fatalf("renderTemplate: %v", err)
+	}
+	if string(manifest) != "name: train.sh\n" {
+		t.Errorf("unexpected manifest %q", manifest)
+	}
+}
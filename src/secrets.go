@@ -0,0 +1,312 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// redactedValue replaces the values of secret variables in logs, the history and the UI
+const redactedValue = "********"
+
+// minRedactLength is the length below which values aren't redacted from free text, where
+// replacing them would garble everything else
+const minRedactLength = 4
+
+// secretNamePattern matches variable names that usually hold credentials, such as HF_TOKEN or
+// WANDB_API_KEY. Annotating a variable with secret=false opts it out.
+var secretNamePattern = regexp.MustCompile(`(?i)(^|_)(TOKEN|KEY|APIKEY|SECRET|PASSWORD|PASSWD|CREDENTIALS?)(_|$)`)
+
+// isSecretName reports whether a variable is secret by its name alone
+func isSecretName(name string) bool {
+	return secretNamePattern.MatchString(name)
+}
+
+// secretValues returns the non-empty values of the configuration's secret variables by name
+func secretValues(config Config) map[string]string {
+	specs := map[string]*VarSpec{}
+	if content, err := readTemplate(config.Template); err == nil {
+		if parsed, err := parseVarSpecs(content); err == nil {
+			specs = parsed
+		}
+	}
+
+	secrets := make(map[string]string)
+	for _, env := range config.EnvVars {
+		if env.Value != "" && varSpecFor(specs, env.Key).Secret {
+			secrets[env.Key] = env.Value
+		}
+	}
+	return secrets
+}
+
+// secretNames returns the sorted names of the secret variables
+func secretNames(secrets map[string]string) []string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// redactConfig returns a copy of the configuration with the values of secret variables masked
+func redactConfig(config Config) Config {
+	secrets := secretValues(config)
+	if len(secrets) == 0 {
+		return config
+	}
+	config.EnvVars = append([]EnvVar(nil), config.EnvVars...)
+	for i, env := range config.EnvVars {
+		if _, secret := secrets[env.Key]; secret {
+			config.EnvVars[i].Value = redactedValue
+		}
+	}
+	return config
+}
+
+// withoutSecrets returns a copy of the configuration with the values of secret variables
+// left empty, for files that shouldn't hold them such as drafts
+func withoutSecrets(config Config) Config {
+	secrets := secretValues(config)
+	if len(secrets) == 0 {
+		return config
+	}
+	config.EnvVars = append([]EnvVar(nil), config.EnvVars...)
+	for name := range secrets {
+		config.SetEnvVar(name, "")
+	}
+	return config
+}
+
+// restoreSecrets puts the secret values back into variables still holding redactedValue,
+// after a redacted configuration was edited
+func restoreSecrets(envVars []EnvVar, secrets map[string]string) {
+	for i, env := range envVars {
+		if value, secret := secrets[env.Key]; secret && env.Value == redactedValue {
+			envVars[i].Value = value
+		}
+	}
+}
+
+// secretMarker stands in for the value of a secret variable in a manifest opened in an editor
+func secretMarker(name string) string {
+	return redactedValue + name
+}
+
+// maskSecrets replaces the secret values in text with markers naming their variables, which
+// unmaskSecrets turns back into the values
+func maskSecrets(text string, secrets map[string]string) string {
+	names := make([]string, 0, len(secrets))
+	for name, value := range secrets {
+		if len(value) >= minRedactLength {
+			names = append(names, name)
+		}
+	}
+	// Longer values first, so a value containing another is masked as a whole
+	sort.Slice(names, func(i, j int) bool {
+		return len(secrets[names[i]]) > len(secrets[names[j]])
+	})
+	for _, name := range names {
+		text = strings.ReplaceAll(text, secrets[name], secretMarker(name))
+	}
+	return text
+}
+
+// unmaskSecrets replaces the markers left by maskSecrets with the secret values
+func unmaskSecrets(text string, secrets map[string]string) string {
+	names := secretNames(secrets)
+	// Longer names first, so HF_TOKEN doesn't match the marker of HF_TOKEN_2
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+	for _, name := range names {
+		text = strings.ReplaceAll(text, secretMarker(name), secrets[name])
+	}
+	return text
+}
+
+// redactText masks every occurrence of the secret values in text
+func redactText(text string, secrets map[string]string) string {
+	values := make([]string, 0, len(secrets))
+	for _, value := range secrets {
+		if len(value) >= minRedactLength {
+			values = append(values, value)
+		}
+	}
+	// Longer values first, so a value containing another is masked as a whole
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, redactedValue)
+	}
+	return text
+}
+
+// redactOverlay returns the overlay with the secret values masked in its strings
+func redactOverlay(overlay map[string]interface{}, secrets map[string]string) map[string]interface{} {
+	if len(overlay) == 0 || len(secrets) == 0 {
+		return overlay
+	}
+	data, err := json.Marshal(overlay)
+	if err != nil {
+		return nil
+	}
+	var redacted map[string]interface{}
+	if err := json.Unmarshal([]byte(redactText(string(data), secrets)), &redacted); err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// containsString reports whether any string in a decoded object contains value
+func containsString(object interface{}, value string) bool {
+	switch v := object.(type) {
+	case string:
+		return strings.Contains(v, value)
+	case map[string]interface{}:
+		for key, item := range v {
+			if strings.Contains(key, value) || containsString(item, value) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if containsString(item, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// injectSecrets makes the environment variables of the job's containers that hold a secret
+// value read it from a Secret instead, and returns that Secret. It fails when a secret value
+// is used anywhere else in the manifest, where it would still be submitted as is.
+func injectSecrets(objects []*unstructured.Unstructured, secrets map[string]string) (*corev1.Secret, error) {
+	i, err := findJob(objects)
+	if err != nil {
+		return nil, err
+	}
+	job := objects[i]
+
+	base := job.GetName()
+	if base == "" {
+		base = strings.TrimSuffix(job.GetGenerateName(), "-") + "-" + utilrand.String(5)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      base + "-secrets",
+			Namespace: job.GetNamespace(),
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: make(map[string]string),
+	}
+
+	for _, field := range []string{"containers", "initContainers"} {
+		containers, _, _ := unstructured.NestedSlice(job.Object, "spec", "template", "spec", field)
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			env, _, _ := unstructured.NestedSlice(container, "env")
+			for _, e := range env {
+				entry, ok := e.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				value, _ := entry["value"].(string)
+				for name, secretValue := range secrets {
+					if value != secretValue {
+						continue
+					}
+					delete(entry, "value")
+					entry["valueFrom"] = map[string]interface{}{
+						"secretKeyRef": map[string]interface{}{"name": secret.Name, "key": name},
+					}
+					secret.StringData[name] = secretValue
+					break
+				}
+			}
+			unstructured.SetNestedSlice(container, env, "env")
+		}
+		if containers != nil {
+			unstructured.SetNestedSlice(job.Object, containers, "spec", "template", "spec", field)
+		}
+	}
+
+	for _, name := range secretNames(secrets) {
+		for _, obj := range objects {
+			if containsString(obj.Object, secrets[name]) {
+				return nil, fmt.Errorf("%s is used in the %s other than as a container environment variable, so it can't be passed through a Secret",
+					name, obj.GetKind())
+			}
+		}
+	}
+
+	if len(secret.StringData) == 0 {
+		return nil, nil
+	}
+	return secret, nil
+}
+
+// createObjectsWithSecret creates the Secret holding the job's secret values, if any, and then
+// the objects. The Secret is owned by the Job or CronJob so Kubernetes deletes it with them.
+func (c *KubeClients) createObjectsWithSecret(ctx context.Context, objects []*unstructured.Unstructured, secret *corev1.Secret) ([]*unstructured.Unstructured, error) {
+	if secret == nil {
+		return c.createObjects(ctx, objects)
+	}
+
+	if secret.Namespace == "" {
+		secret.Namespace = c.Namespace
+	}
+	secretsClient := c.Clientset.CoreV1().Secrets(secret.Namespace)
+	if _, err := secretsClient.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to create Secret: %v", err)
+	}
+
+	created, err := c.createObjects(ctx, objects)
+
+	var owner *unstructured.Unstructured
+	for _, obj := range created {
+		if obj.GetKind() == "Job" || obj.GetKind() == "CronJob" {
+			owner = obj
+			break
+		}
+	}
+	if owner == nil {
+		// Nothing would delete the Secret without its job
+		secretsClient.Delete(ctx, secret.Name, metav1.DeleteOptions{})
+		return created, err
+	}
+
+	patch, ownErr := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"ownerReferences": []metav1.OwnerReference{{
+				APIVersion: owner.GetAPIVersion(),
+				Kind:       owner.GetKind(),
+				Name:       owner.GetName(),
+				UID:        owner.GetUID(),
+			}},
+		},
+	})
+	if ownErr == nil {
+		_, ownErr = secretsClient.Patch(ctx, secret.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	if ownErr != nil && err == nil {
+		err = fmt.Errorf("failed to make %s %s own Secret %s, delete it with the %s: %v",
+			owner.GetKind(), owner.GetName(), secret.Name, owner.GetKind(), ownErr)
+	}
+	return created, err
+}
@@ -0,0 +1,141 @@
+package src
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIsSecretName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"HF_TOKEN":      true,
+		"WANDB_API_KEY": true,
+		"DB_PASSWORD":   true,
+		"AWS_SECRET":    true,
+		"credentials":   true,
+		"TOKENIZER":     false,
+		"KEYBOARD":      false,
+		"GPU_NUM":       false,
+	} {
+		if got := isSecretName(name); got != want {
+			t.Errorf("isSecretName(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	secrets := map[string]string{
+		"HF_TOKEN":   "hf_abc",
+		"HF_TOKEN_2": "hf_abc123",
+		"PIN":        "42",
+	}
+	text := "a: hf_abc\nb: hf_abc123\nc: 42\n"
+
+	masked := maskSecrets(text, secrets)
+	want := "a: " + secretMarker("HF_TOKEN") + "\nb: " + secretMarker("HF_TOKEN_2") + "\nc: 42\n"
+	if masked != want {
+		t.Errorf("maskSecrets = %q, want %q", masked, want)
+	}
+	if unmasked := unmaskSecrets(masked, secrets); unmasked != text {
+		t.Errorf("unmaskSecrets = %q, want %q", unmasked, text)
+	}
+
+	if redacted := redactText(text, secrets); redacted != "a: ********\nb: ********\nc: 42\n" {
+		t.Errorf("redactText = %q", redacted)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	setupConfigs(t)
+	config := Config{EnvVars: []EnvVar{{Key: "GPU_NUM", Value: "2"}, {Key: "HF_TOKEN", Value: "hf_abc"}}}
+
+	redacted := redactConfig(config)
+	if value, _ := redacted.GetEnvVar("HF_TOKEN"); value != redactedValue {
+		t.Errorf("redacted HF_TOKEN = %q", value)
+	}
+	if value, _ := redacted.GetEnvVar("GPU_NUM"); value != "2" {
+		t.Errorf("redacted GPU_NUM = %q", value)
+	}
+
+	cleared := withoutSecrets(config)
+	if value, _ := cleared.GetEnvVar("HF_TOKEN"); value != "" {
+		t.Errorf("HF_TOKEN without secrets = %q", value)
+	}
+	if value, _ := config.GetEnvVar("HF_TOKEN"); value != "hf_abc" {
+		t.Errorf("the original configuration was changed to %q", value)
+	}
+
+	restoreSecrets(redacted.EnvVars, secretValues(config))
+	if !reflect.DeepEqual(redacted.EnvVars, config.EnvVars) {
+		t.Errorf("restored %v, want %v", redacted.EnvVars, config.EnvVars)
+	}
+}
+
+func TestRedactOverlay(t *testing.T) {
+	overlay := map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{"token": "hf_abc"}}}
+	redacted := redactOverlay(overlay, map[string]string{"HF_TOKEN": "hf_abc"})
+	want := map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{"token": redactedValue}}}
+	if !reflect.DeepEqual(redacted, want) {
+		t.Errorf("redactOverlay = %v, want %v", redacted, want)
+	}
+}
+
+const secretJobManifest = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: train
+spec:
+  template:
+    spec:
+      containers:
+        - name: main
+          image: ubuntu:22.04
+          args: ["%s"]
+          env:
+            - name: HF_TOKEN
+              value: hf_abc123
+            - name: GPU_NUM
+              value: "2"
+      restartPolicy: Never
+`
+
+func TestInjectSecrets(t *testing.T) {
+	secrets := map[string]string{"HF_TOKEN": "hf_abc123"}
+	objects, err := decodeManifest([]byte(strings.Replace(secretJobManifest, "%s", "train.py", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := injectSecrets(objects, secrets)
+	if err != nil {
+		t.Fatalf("injectSecrets: %v", err)
+	}
+	if secret == nil || secret.Name != "train-secrets" || secret.StringData["HF_TOKEN"] != "hf_abc123" {
+		t.Fatalf("unexpected Secret %+v", secret)
+	}
+
+	containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+	env := containers[0].(map[string]interface{})["env"].([]interface{})
+	want := []interface{}{
+		map[string]interface{}{"name": "HF_TOKEN", "valueFrom": map[string]interface{}{
+			"secretKeyRef": map[string]interface{}{"name": "train-secrets", "key": "HF_TOKEN"},
+		}},
+		map[string]interface{}{"name": "GPU_NUM", "value": "2"},
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("env = %v, want %v", env, want)
+	}
+}
+
+func TestInjectSecretsUsedElsewhere(t *testing.T) {
+	objects, err := decodeManifest([]byte(strings.Replace(secretJobManifest, "%s", "--token=hf_abc123", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = injectSecrets(objects, map[string]string{"HF_TOKEN": "hf_abc123"})
+	if err == nil || !strings.Contains(err.Error(), "HF_TOKEN is used in the Job") {
+		t.Errorf("injectSecrets error = %v, want the secret reported as used outside env", err)
+	}
+}
@@ -0,0 +1,45 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const settingsFile = "settings.yaml"
+
+// Settings holds user preferences read from ~/.kstool/settings.yaml
+type Settings struct {
+	// GPUProducts is offered for GPU_PRODUCT when the cluster can't be queried
+	GPUProducts []string `yaml:"gpu_products,omitempty"`
+	// RequiredLabels overrides the labels every job must carry
+	RequiredLabels []string `yaml:"required_labels,omitempty"`
+	// Editor is the editor command, with arguments, used when $VISUAL and $EDITOR are unset
+	Editor string `yaml:"editor,omitempty"`
+	// InjectSecrets passes the values of secret variables to jobs through a Secret instead of the manifest
+	InjectSecrets bool `yaml:"inject_secrets,omitempty"`
+}
+
+// loadSettings reads the settings file, returning empty settings when it doesn't exist
+func loadSettings() (*Settings, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %v", err)
+	}
+
+	settings := &Settings{}
+	data, err := os.ReadFile(filepath.Join(homeDir, configDir, settingsFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return nil, fmt.Errorf("failed to read settings: %v", err)
+	}
+
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse settings: %v", err)
+	}
+	return settings, nil
+}
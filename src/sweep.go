@@ -0,0 +1,374 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	// SweepLabel groups the jobs submitted by one sweep
+	SweepLabel = "kstool/sweep"
+	// sweepValuesAnnotation records the variable values of one sweep job
+	sweepValuesAnnotation = "kstool/sweep-values"
+	// maxSweepJobs guards against submitting a huge grid by accident
+	maxSweepJobs = 200
+)
+
+// SweepAxis is a variable that takes several values in a sweep
+type SweepAxis struct {
+	Name   string
+	Values []string
+}
+
+// SweepPoint is the set of variable values of one job in a sweep
+type SweepPoint []EnvVar
+
+func (p SweepPoint) String() string {
+	parts := make([]string, len(p))
+	for i, env := range p {
+		parts[i] = env.Key + "=" + env.Value
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseSweepValues parses the values a variable takes in a sweep: a comma separated list
+// such as "1e-3,3e-4", or a range "start..end" with an optional step, e.g. "1..5" or "0.1..0.5:0.1".
+// A range may have at most limit values.
+func parseSweepValues(text string, limit int) ([]string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	if bounds, step, isRange := strings.Cut(text, ".."); isRange && !strings.Contains(text, ",") {
+		end, stepText, hasStep := strings.Cut(step, ":")
+		if !hasStep {
+			stepText = "1"
+		}
+		return expandRange(strings.TrimSpace(bounds), strings.TrimSpace(end), strings.TrimSpace(stepText), limit)
+	}
+
+	var values []string
+	for _, value := range strings.Split(text, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values, nil
+}
+
+// expandRange lists the values from start to end inclusive, failing when there are more than
+// limit. Integer bounds and steps give integers.
+func expandRange(startText, endText, stepText string, limit int) ([]string, error) {
+	start, err := strconv.ParseFloat(startText, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range start %q", startText)
+	}
+	end, err := strconv.ParseFloat(endText, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range end %q", endText)
+	}
+	step, err := strconv.ParseFloat(stepText, 64)
+	if err != nil || step <= 0 {
+		return nil, fmt.Errorf("invalid range step %q, it must be a positive number", stepText)
+	}
+	if end < start {
+		return nil, fmt.Errorf("range end %s is before its start %s", endText, startText)
+	}
+
+	count := int(math.Floor((end-start)/step+1e-9)) + 1
+	if count > limit {
+		return nil, fmt.Errorf("range %s..%s:%s has more than %d values", startText, endText, stepText, limit)
+	}
+
+	integers := isInteger(startText) && isInteger(endText) && isInteger(stepText)
+	values := make([]string, count)
+	for i := range values {
+		value := start + float64(i)*step
+		if integers {
+			values[i] = strconv.FormatInt(int64(value), 10)
+		} else {
+			// Round away float noise such as 0.30000000000000004
+			values[i] = strconv.FormatFloat(math.Round(value*1e9)/1e9, 'g', -1, 64)
+		}
+	}
+	return values, nil
+}
+
+// isInteger reports whether text is an integer literal
+func isInteger(text string) bool {
+	_, err := strconv.ParseInt(text, 10, 64)
+	return err == nil
+}
+
+// sweepSize returns the number of points in the cartesian product of the axes
+func sweepSize(axes []SweepAxis) int {
+	size := 1
+	for _, axis := range axes {
+		size *= len(axis.Values)
+		if size > maxSweepJobs*1000 {
+			// Large enough to be rejected without overflowing
+			return size
+		}
+	}
+	return size
+}
+
+// expandSweep returns the cartesian product of the axes, or a random sample of sample points
+// when sample is positive and smaller than the product. At most limit points are allowed.
+func expandSweep(axes []SweepAxis, sample int, rng *rand.Rand, limit int) ([]SweepPoint, error) {
+	size := sweepSize(axes)
+	if size > maxSweepJobs*1000 {
+		return nil, fmt.Errorf("the sweep has too many combinations to sample from")
+	}
+	count := size
+	if sample > 0 && sample < size {
+		count = sample
+	}
+	if count > limit {
+		return nil, fmt.Errorf("%d combinations are more than the limit of %d", count, limit)
+	}
+
+	// point converts an index into the product to its values, the last axis varying fastest
+	point := func(index int) SweepPoint {
+		values := make(SweepPoint, len(axes))
+		for i := len(axes) - 1; i >= 0; i-- {
+			axis := axes[i]
+			values[i] = EnvVar{Key: axis.Name, Value: axis.Values[index%len(axis.Values)]}
+			index /= len(axis.Values)
+		}
+		return values
+	}
+
+	indexes := make([]int, size)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	if count < size {
+		indexes = rng.Perm(size)[:count]
+		sort.Ints(indexes)
+	}
+
+	points := make([]SweepPoint, len(indexes))
+	for i, index := range indexes {
+		points[i] = point(index)
+	}
+	return points, nil
+}
+
+// newSweepID returns a label value identifying a new sweep. The random suffix keeps sweeps
+// started in the same second apart.
+func newSweepID() string {
+	return "sweep-" + time.Now().Format("20060102-150405") + "-" + utilrand.String(4)
+}
+
+// sweepConfig returns the configuration of one sweep job
+func sweepConfig(config Config, point SweepPoint) Config {
+	config.EnvVars = append([]EnvVar(nil), config.EnvVars...)
+	for _, env := range point {
+		config.SetEnvVar(env.Key, env.Value)
+	}
+	return config
+}
+
+// labelSweepJob adds the sweep label and values to the jobs of a rendered manifest.
+// Fixed job names get the index of the job in the sweep so they don't collide.
+func labelSweepJob(objects []*unstructured.Unstructured, sweepID string, index int, point SweepPoint) {
+	for _, obj := range objects {
+		if obj.GroupVersionKind() != batchv1.SchemeGroupVersion.WithKind("Job") {
+			continue
+		}
+
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[SweepLabel] = sweepID
+		obj.SetLabels(labels)
+
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[sweepValuesAnnotation] = point.String()
+		obj.SetAnnotations(annotations)
+
+		// Label the pods too so they can be selected by sweep
+		podLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+		if podLabels == nil {
+			podLabels = make(map[string]string)
+		}
+		podLabels[SweepLabel] = sweepID
+		unstructured.SetNestedStringMap(obj.Object, podLabels, "spec", "template", "metadata", "labels")
+
+		if obj.GetName() != "" && obj.GetGenerateName() == "" {
+			obj.SetName(fmt.Sprintf("%s-%d", obj.GetName(), index))
+		}
+	}
+}
+
+// submitSweep creates one job per sweep point, all labelled with sweepID.
+// It returns the number of jobs created before any failure.
+func submitSweep(ctx context.Context, clients *KubeClients, name string, config Config, points []SweepPoint, sweepID string) (int, error) {
+	user, _ := GetCurrentUser()
+	LogToSyslog(fmt.Sprintf("Timestamp: %s, User: %s, Submitting sweep %s with %d jobs from Config: %v",
+		time.Now().Format(time.RFC3339), user, sweepID, len(points), redactConfig(config)))
+
+	for i, point := range points {
+		manifest, err := renderJobConfig(sweepConfig(config, point))
+		if err != nil {
+			return i, fmt.Errorf("job %d (%s): %w", i, point, err)
+		}
+
+		objects, err := decodeManifest(manifest)
+		if err != nil {
+			return i, fmt.Errorf("job %d (%s): %w", i, point, err)
+		}
+		labelSweepJob(objects, sweepID, i, point)
+
+		if _, err := submitJob(ctx, clients, name, sweepConfig(config, point), objects); err != nil {
+			return i, fmt.Errorf("job %d (%s): %w", i, point, err)
+		}
+	}
+	return len(points), nil
+}
+
+// addSweepFields adds an input field per variable for the values it takes
+func addSweepFields(form *tview.Form, config *Config, specs map[string]*VarSpec) {
+	for _, env := range config.EnvVars {
+		placeholder := env.Value
+		if placeholder != "" && varSpecFor(specs, env.Key).Secret {
+			placeholder = redactedValue
+		}
+		form.AddInputField(env.Key, "", 40, nil, nil)
+		form.GetFormItemByLabel(env.Key).(*tview.InputField).SetPlaceholder(placeholder)
+	}
+}
+
+// readSweepAxes parses the values entered in the fields added by addSweepFields. Ranges may
+// have at most limit values.
+func readSweepAxes(form *tview.Form, config *Config, limit int) ([]SweepAxis, error) {
+	var axes []SweepAxis
+	for _, env := range config.EnvVars {
+		values, err := parseSweepValues(form.GetFormItemByLabel(env.Key).(*tview.InputField).GetText(), limit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", env.Key, err)
+		}
+		if len(values) > 0 {
+			axes = append(axes, SweepAxis{Name: env.Key, Values: values})
+		}
+	}
+	if len(axes) == 0 {
+		return nil, fmt.Errorf("enter the values of at least one variable")
+	}
+	return axes, nil
+}
+
+// showSweepDialog asks which variables to sweep over and how many jobs to submit
+func (f *CreateJobForm) showSweepDialog(config *Config, specs map[string]*VarSpec, back tview.Primitive) {
+	form := tview.NewForm()
+	form.SetBorder(true).
+		SetTitle("Sweep: values as a list (1e-3,3e-4) or range (1..5, 0.1..0.5:0.1); empty keeps the value").
+		SetTitleAlign(tview.AlignLeft)
+
+	addSweepFields(form, config, specs)
+	form.AddInputField("Random sample (0 = all)", "0", 10, tview.InputFieldInteger, nil)
+
+	form.AddButton("Preview", func() {
+		axes, err := readSweepAxes(form, config, maxSweepJobs)
+		if err != nil {
+			showError(f.app, form, err.Error())
+			return
+		}
+
+		sample, _ := strconv.Atoi(form.GetFormItemByLabel("Random sample (0 = all)").(*tview.InputField).GetText())
+		points, err := expandSweep(axes, sample, rand.New(rand.NewSource(time.Now().UnixNano())), maxSweepJobs)
+		if err != nil {
+			showError(f.app, form, err.Error())
+			return
+		}
+
+		// Check every job's values against the template annotations
+		var problems []string
+		for i, point := range points {
+			jobConfig := sweepConfig(*config, point)
+			for _, problem := range validateConfig(&jobConfig, specs) {
+				problems = append(problems, fmt.Sprintf("job %d: %s", i, problem))
+			}
+		}
+		if len(problems) > 0 {
+			showError(f.app, form, "Some sweep values are invalid:\n\n"+strings.Join(problems, "\n"))
+			return
+		}
+
+		f.showSweepPreview(*config, points, sweepSize(axes), form)
+	})
+	form.AddButton("Cancel", func() {
+		f.app.SetRoot(back, true)
+	})
+	form.SetCancelFunc(func() {
+		f.app.SetRoot(back, true)
+	})
+
+	f.app.SetRoot(form, true)
+}
+
+// showSweepPreview lists the jobs of a sweep and submits them on confirmation
+func (f *CreateJobForm) showSweepPreview(config Config, points []SweepPoint, combinations int, back tview.Primitive) {
+	sweepID := newSweepID()
+
+	preview := tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+	preview.SetBorder(true).
+		SetTitle(fmt.Sprintf("%s: %d jobs of %d combinations, labelled %s=%s", sweepID, len(points), combinations, SweepLabel, sweepID)).
+		SetTitleAlign(tview.AlignLeft)
+
+	var text strings.Builder
+	for i, point := range points {
+		fmt.Fprintf(&text, "[yellow]%3d[-]  %s\n", i, tview.Escape(strings.ReplaceAll(point.String(), ",", "  ")))
+	}
+	preview.SetText(text.String())
+
+	buttons := tview.NewForm().SetButtonsAlign(tview.AlignCenter)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(preview, 0, 1, false).
+		AddItem(buttons, 3, 0, true)
+
+	buttons.AddButton(fmt.Sprintf("Submit %d Jobs", len(points)), func() {
+		// The jobs only differ in the swept values, so check the first one in full
+		first := sweepConfig(config, points[0])
+		if problems := validateManifest(f.ctx, f.clients, first); len(problems) > 0 {
+			showError(f.app, layout, "The job manifest is invalid:\n\n"+formatFieldErrors(problems))
+			return
+		}
+		f.confirmLint(first, layout, func() {
+			created, err := submitSweep(f.ctx, f.clients, f.configName, config, points, sweepID)
+			if err != nil {
+				showError(f.app, layout, fmt.Sprintf("Submitted %d of %d jobs, then failed: %v", created, len(points), err))
+				return
+			}
+			if f.configName != "" {
+				recordConfigApplied(f.configName)
+			}
+			f.onClose()
+		})
+	})
+	buttons.AddButton("Back", func() {
+		f.app.SetRoot(back, true)
+	})
+	buttons.SetCancelFunc(func() {
+		f.app.SetRoot(back, true)
+	})
+
+	f.app.SetRoot(layout, true)
+}
@@ -0,0 +1,38 @@
+package src
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSweepValues(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+		err  string
+	}{
+		{text: "", want: nil},
+		{text: "a, b,,c ", want: []string{"a", "b", "c"}},
+		{text: "1..4", want: []string{"1", "2", "3", "4"}},
+		{text: "0..10:4", want: []string{"0", "4", "8"}},
+		{text: "0.1..0.3:0.1", want: []string{"0.1", "0.2", "0.3"}},
+		{text: "1e-4, 1e-3", want: []string{"1e-4", "1e-3"}},
+		{text: "1..100", err: "more than 10 values"},
+		{text: "4..1", err: "before its start"},
+		{text: "1..4:0", err: "invalid range step"},
+		{text: "a..b", err: "invalid range start"},
+	}
+	for _, tt := range tests {
+		got, err := parseSweepValues(tt.text, 10)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseSweepValues(%q) error = %v, want %q", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSweepValues(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package src
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholder operators, following shell parameter expansion
+const (
+	opValue          = ""   // ${VAR}
+	opDefault        = ":-" // ${VAR:-default}, default when unset or empty
+	opDefaultUnset   = "-"  // ${VAR-default}, default when unset
+	opRequired       = ":?" // ${VAR:?message}, error when unset or empty
+	opRequiredUnset  = "?"  // ${VAR?message}, error when unset
+	opAlternate      = ":+" // ${VAR:+alternate}, alternate when set and not empty
+	opAlternateUnset = "+"  // ${VAR+alternate}, alternate when set
+)
+
+// operators is ordered so two-character operators are matched first
+var operators = []string{opDefault, opRequired, opAlternate, opDefaultUnset, opRequiredUnset, opAlternateUnset}
+
+// Placeholder is one variable reference in a template
+type Placeholder struct {
+	Name string
+	Op   string
+	// Arg is the raw default value, error message or alternate value
+	Arg    string
+	Line   int
+	Column int
+	// Offset and End are the byte offsets of the placeholder in the template
+	Offset int
+	End    int
+
+	arg []templateSegment
+}
+
+// templateSegment is either literal text or a placeholder
+type templateSegment struct {
+	literal     string
+	placeholder *Placeholder
+}
+
+// Template is a parsed job template.
+//
+// Placeholders use the shell forms ${VAR}, ${VAR:-default}, ${VAR-default}, ${VAR:?message},
+// ${VAR?message}, ${VAR:+alternate} and ${VAR+alternate}. Defaults may contain balanced braces
+// and nested placeholders. $${ is an escaped, literal ${. Bare $VAR and shell-only forms such
+// as ${#VAR} or ${VAR[0]} are left untouched so scripts embedded in the template keep working,
+// and so is ${VAR} unless the template declares VAR, with an operator form or a `# kstool:`
+// annotation.
+type Template struct {
+	Content      []byte
+	Placeholders []*Placeholder
+
+	segments   []templateSegment
+	lineStarts []int
+	// declared holds the variables whose ${VAR} placeholders are substituted
+	declared map[string]bool
+}
+
+// TemplateVariable is a variable declared by a template
+type TemplateVariable struct {
+	Name     string
+	Default  string
+	Required bool
+	// Line is the line of the first occurrence
+	Line int
+}
+
+// RenderError reports a required variable that has no value
+type RenderError struct {
+	Name    string
+	Line    int
+	Message string
+}
+
+func (e *RenderError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Name, e.Message)
+}
+
+// ParseTemplate parses the placeholders of a template
+func ParseTemplate(content []byte) (*Template, error) {
+	return parseTemplate(content, nil)
+}
+
+// declaredVariables returns the variables a template declares, see declaredNames
+func declaredVariables(content []byte) map[string]bool {
+	t, err := ParseTemplate(content)
+	if err != nil {
+		return map[string]bool{}
+	}
+	return t.declared
+}
+
+// parseTemplate parses the placeholders of a template, or of a part of one when declared
+// holds the variables declared by the whole template
+func parseTemplate(content []byte, declared map[string]bool) (*Template, error) {
+	t := &Template{Content: content, lineStarts: []int{0}}
+	for i, c := range content {
+		if c == '\n' {
+			t.lineStarts = append(t.lineStarts, i+1)
+		}
+	}
+
+	segments, err := t.parse(0, len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	if declared == nil {
+		declared = t.declaredNames()
+	}
+	t.declared = declared
+	t.segments = t.keepUndeclared(segments, declared)
+	var placeholders []*Placeholder
+	for _, placeholder := range t.Placeholders {
+		if placeholder.Op != opValue || declared[placeholder.Name] {
+			placeholders = append(placeholders, placeholder)
+		}
+	}
+	t.Placeholders = placeholders
+	return t, nil
+}
+
+// declaredNames returns the variables the template declares: those written with an operator,
+// such as ${VAR:-default}, and those with a `# kstool:` annotation
+func (t *Template) declaredNames() map[string]bool {
+	declared := make(map[string]bool)
+	for _, placeholder := range t.Placeholders {
+		if placeholder.Op != opValue {
+			declared[placeholder.Name] = true
+		}
+	}
+	for _, line := range strings.Split(string(t.Content), "\n") {
+		if matches := annotationPattern.FindStringSubmatch(line); matches != nil {
+			declared[matches[1]] = true
+		}
+	}
+	return declared
+}
+
+// keepUndeclared turns the ${VAR} placeholders of undeclared variables back into literal
+// text, so shell variables such as ${HOME} in embedded scripts reach the container as written
+func (t *Template) keepUndeclared(segments []templateSegment, declared map[string]bool) []templateSegment {
+	var kept []templateSegment
+	for _, segment := range segments {
+		if p := segment.placeholder; p != nil {
+			if p.Op == opValue && !declared[p.Name] {
+				segment = templateSegment{literal: string(t.Content[p.Offset:p.End])}
+			} else {
+				p.arg = t.keepUndeclared(p.arg, declared)
+			}
+		}
+		// Merge adjacent literals
+		if segment.placeholder == nil && len(kept) > 0 && kept[len(kept)-1].placeholder == nil {
+			kept[len(kept)-1].literal += segment.literal
+			continue
+		}
+		kept = append(kept, segment)
+	}
+	return kept
+}
+
+// position converts a byte offset into a 1-based line and column
+func (t *Template) position(offset int) (int, int) {
+	line := sort.Search(len(t.lineStarts), func(i int) bool {
+		return t.lineStarts[i] > offset
+	})
+	return line, offset - t.lineStarts[line-1] + 1
+}
+
+// parse splits content[start:end] into literal and placeholder segments
+func (t *Template) parse(start, end int) ([]templateSegment, error) {
+	var segments []templateSegment
+	var literal strings.Builder
+
+	flush := func() {
+		if literal.Len() > 0 {
+			segments = append(segments, templateSegment{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	content := t.Content
+	for i := start; i < end; {
+		// $${ is an escaped ${
+		if bytes.HasPrefix(content[i:end], []byte("$${")) {
+			literal.WriteString("${")
+			i += 3
+			continue
+		}
+
+		if !bytes.HasPrefix(content[i:end], []byte("${")) {
+			literal.WriteByte(content[i])
+			i++
+			continue
+		}
+
+		placeholder, next, err := t.parsePlaceholder(i, end)
+		if err != nil {
+			return nil, err
+		}
+		if placeholder == nil {
+			// Not a template placeholder, keep the shell syntax as it is
+			literal.WriteString("${")
+			i += 2
+			continue
+		}
+
+		flush()
+		segments = append(segments, templateSegment{placeholder: placeholder})
+		i = next
+	}
+	flush()
+	return segments, nil
+}
+
+// parsePlaceholder parses the placeholder starting at offset, returning nil when the
+// text is shell syntax rather than a template placeholder
+func (t *Template) parsePlaceholder(offset, end int) (*Placeholder, int, error) {
+	content := t.Content
+	i := offset + 2
+
+	nameStart := i
+	for i < end && isNameChar(content[i], i == nameStart) {
+		i++
+	}
+	if i == nameStart {
+		return nil, 0, nil
+	}
+
+	line, column := t.position(offset)
+	if i == end {
+		return nil, 0, fmt.Errorf("line %d, column %d: unterminated placeholder", line, column)
+	}
+
+	placeholder := &Placeholder{
+		Name:   string(content[nameStart:i]),
+		Line:   line,
+		Column: column,
+		Offset: offset,
+	}
+
+	if content[i] == '}' {
+		placeholder.End = i + 1
+		t.Placeholders = append(t.Placeholders, placeholder)
+		return placeholder, i + 1, nil
+	}
+
+	for _, op := range operators {
+		if bytes.HasPrefix(content[i:end], []byte(op)) {
+			placeholder.Op = op
+			break
+		}
+	}
+	if placeholder.Op == "" {
+		return nil, 0, nil
+	}
+	i += len(placeholder.Op)
+
+	// Find the closing brace, allowing balanced braces inside the argument
+	argStart := i
+	depth := 0
+	for ; i < end; i++ {
+		if content[i] == '{' {
+			depth++
+		} else if content[i] == '}' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+	}
+	if i == end {
+		return nil, 0, fmt.Errorf("line %d, column %d: unterminated placeholder ${%s", line, column, placeholder.Name)
+	}
+
+	placeholder.Arg = string(content[argStart:i])
+	placeholder.End = i + 1
+
+	// Register the placeholder before its nested ones so occurrences stay in document order
+	t.Placeholders = append(t.Placeholders, placeholder)
+	arg, err := t.parse(argStart, i)
+	if err != nil {
+		return nil, 0, err
+	}
+	placeholder.arg = arg
+	return placeholder, i + 1, nil
+}
+
+// isNameChar reports whether c can appear in a variable name
+func isNameChar(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+	return false
+}
+
+// Variables returns the declared variables in order of first occurrence.
+// The default of a variable is taken from its first occurrence that has one.
+func (t *Template) Variables() []TemplateVariable {
+	var variables []TemplateVariable
+	index := make(map[string]int)
+	unset := func(string) (string, bool) { return "", false }
+
+	for _, placeholder := range t.Placeholders {
+		i, exists := index[placeholder.Name]
+		if !exists {
+			i = len(variables)
+			index[placeholder.Name] = i
+			variables = append(variables, TemplateVariable{Name: placeholder.Name, Line: placeholder.Line})
+		}
+
+		variable := &variables[i]
+		switch placeholder.Op {
+		case opDefault, opDefaultUnset:
+			if variable.Default == "" {
+				// Nested placeholders in defaults resolve to their own defaults
+				var buf bytes.Buffer
+				if err := t.renderSegments(&buf, placeholder.arg, unset); err == nil {
+					variable.Default = buf.String()
+				}
+			}
+		case opRequired, opRequiredUnset:
+			variable.Required = true
+		}
+	}
+	return variables
+}
+
+// Render substitutes the placeholders. lookup returns a variable's value and whether it is set.
+func (t *Template) Render(lookup func(name string) (string, bool)) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.renderSegments(&buf, t.segments, lookup); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderSegments writes the rendered segments to buf
+func (t *Template) renderSegments(buf *bytes.Buffer, segments []templateSegment, lookup func(name string) (string, bool)) error {
+	for _, segment := range segments {
+		if segment.placeholder == nil {
+			buf.WriteString(segment.literal)
+			continue
+		}
+
+		p := segment.placeholder
+		value, set := lookup(p.Name)
+		nonEmpty := set && value != ""
+
+		switch p.Op {
+		case opValue:
+			buf.WriteString(formatValue(buf.Bytes(), t.restOfLine(p), value))
+		case opDefault, opDefaultUnset:
+			if nonEmpty || (set && p.Op == opDefaultUnset) {
+				buf.WriteString(formatValue(buf.Bytes(), t.restOfLine(p), value))
+			} else if err := t.renderSegments(buf, p.arg, lookup); err != nil {
+				return err
+			}
+		case opRequired, opRequiredUnset:
+			if nonEmpty || (set && p.Op == opRequiredUnset) {
+				buf.WriteString(formatValue(buf.Bytes(), t.restOfLine(p), value))
+				continue
+			}
+			message := p.Arg
+			if message == "" {
+				message = "parameter null or not set"
+			}
+			return &RenderError{Name: p.Name, Line: p.Line, Message: message}
+		case opAlternate, opAlternateUnset:
+			if nonEmpty || (set && p.Op == opAlternateUnset) {
+				if err := t.renderSegments(buf, p.arg, lookup); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// restOfLine returns the template text between a placeholder and the end of its line
+func (t *Template) restOfLine(p *Placeholder) []byte {
+	rest := t.Content[p.End:]
+	if end := bytes.IndexByte(rest, '\n'); end != -1 {
+		rest = rest[:end]
+	}
+	return rest
+}
+
+// blockScalarHeader matches a line that starts a YAML block scalar, such as `args: |` or `- >-`
+var blockScalarHeader = regexp.MustCompile(`(^|[:\s-])\s*[|>][-+0-9]*\s*(#.*)?$`)
+
+// plainValuePrefix matches the start of a line up to a plain mapping value or sequence item,
+// such as `  command: ` or `  - `
+var plainValuePrefix = regexp.MustCompile(`^\s*(-\s+)*([^\s#'"\[{-][^#'"]*:\s+)?$`)
+
+// inBlockScalar reports whether the line being rendered, after the rendered text before it,
+// is part of a block scalar
+func inBlockScalar(before []byte, indent int) bool {
+	lines := strings.Split(string(before), "\n")
+	// The last element is the current line
+	for i := len(lines) - 2; i >= 0; i-- {
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+		if len(line)-len(trimmed) < indent {
+			return blockScalarHeader.MatchString(line)
+		}
+	}
+	return false
+}
+
+// formatValue returns a value as it is substituted after the text rendered before it and
+// followed by rest on the same line. Multi-line values are indented to stay inside the
+// block scalar they are substituted into, and become a block scalar themselves when they
+// are the whole value of a mapping key or sequence item.
+func formatValue(before, rest []byte, value string) string {
+	if !strings.Contains(value, "\n") {
+		return value
+	}
+
+	prefix := string(before[bytes.LastIndexByte(before, '\n')+1:])
+	indent := len(prefix) - len(strings.TrimLeft(prefix, " "))
+
+	var lines []string
+	var indentation string
+	switch {
+	case strings.TrimSpace(prefix) == "" || inBlockScalar(before, indent):
+		// Continue the block scalar, or the plain scalar, at the indentation of the line
+		lines = strings.Split(value, "\n")
+		indentation = prefix[:indent]
+	case plainValuePrefix.MatchString(prefix) && len(bytes.TrimSpace(rest)) == 0:
+		// Nest the value as a block scalar one level deeper than its key or item
+		item := strings.TrimLeft(prefix, " -")
+		column := len(prefix) - len(strings.TrimLeft(strings.TrimLeft(prefix, " "), "- "))
+		if strings.TrimSpace(item) != "" {
+			column += 2
+		}
+		header := "|"
+		if !strings.HasSuffix(value, "\n") {
+			header = "|-"
+		}
+		lines = append([]string{header}, strings.Split(strings.TrimSuffix(value, "\n"), "\n")...)
+		indentation = strings.Repeat(" ", column)
+	default:
+		// Inside quotes or other text on the line, the value is substituted as it is
+		return value
+	}
+
+	var formatted strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			formatted.WriteString("\n")
+			if line != "" {
+				formatted.WriteString(indentation)
+			}
+		}
+		formatted.WriteString(line)
+	}
+	return formatted.String()
+}
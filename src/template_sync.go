@@ -0,0 +1,449 @@
+package src
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// upstreamTemplateFile is the pristine copy of the last synced upstream base template,
+	// used as the common ancestor when merging upstream changes into the local copy
+	upstreamTemplateFile  = "base_apply.upstream.yaml"
+	templateSyncFile      = "template_sync.yaml"
+	templateCheckInterval = 24 * time.Hour
+)
+
+// templateSyncState records the upstream version the local base template is based on
+type templateSyncState struct {
+	Version        string    `yaml:"version,omitempty"`
+	ETag           string    `yaml:"etag,omitempty"`
+	CheckedAt      time.Time `yaml:"checked_at,omitempty"`
+	SkippedVersion string    `yaml:"skipped_version,omitempty"`
+}
+
+// templateUpstream is where the base template is published
+type templateUpstream struct {
+	URL    string
+	Client *http.Client
+}
+
+// baseTemplateUpstream can be pointed at another server, e.g. a local one in place of GitHub
+var baseTemplateUpstream = &templateUpstream{
+	URL:    baseConfigURL,
+	Client: &http.Client{Timeout: 10 * time.Second},
+}
+
+// fetch downloads the template. With an ETag, notModified is true when it hasn't changed.
+func (u *templateUpstream) fetch(etag string) (content []byte, newETag string, notModified bool, err error) {
+	req, err := http.NewRequest(http.MethodGet, u.URL, nil)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to create request: %v", err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to download base template: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, etag, true, nil
+	default:
+		return nil, "", false, fmt.Errorf("failed to download base template: %s", resp.Status)
+	}
+
+	content, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to read response body: %v", err)
+	}
+	return content, resp.Header.Get("ETag"), false, nil
+}
+
+// templateVersion identifies a version of a template by its content
+func templateVersion(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// templateSyncPaths returns the paths of the local base template, its upstream copy and the sync state
+func templateSyncPaths() (local, upstream, state string, err error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	dir := filepath.Join(homeDir, configDir)
+	return filepath.Join(dir, "base_apply.yaml"), filepath.Join(dir, upstreamTemplateFile), filepath.Join(dir, templateSyncFile), nil
+}
+
+// loadTemplateSyncState reads the sync state, returning an empty state when there is none
+func loadTemplateSyncState() (*templateSyncState, error) {
+	_, _, statePath, err := templateSyncPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &templateSyncState{}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read template sync state: %v", err)
+	}
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse template sync state: %v", err)
+	}
+	return state, nil
+}
+
+// saveTemplateSyncState writes the sync state
+func saveTemplateSyncState(state *templateSyncState) error {
+	_, _, statePath, err := templateSyncPaths()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal template sync state: %v", err)
+	}
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write template sync state: %v", err)
+	}
+	return nil
+}
+
+// recordUpstreamTemplate stores content as the upstream version the local template is based on
+func recordUpstreamTemplate(state *templateSyncState, content []byte, etag string) error {
+	_, upstreamPath, _, err := templateSyncPaths()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(upstreamPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write upstream template: %v", err)
+	}
+
+	state.Version = templateVersion(content)
+	state.ETag = etag
+	state.SkippedVersion = ""
+	return saveTemplateSyncState(state)
+}
+
+// templateUpdate is a newer upstream base template merged with the local copy
+type templateUpdate struct {
+	Upstream []byte
+	ETag     string
+	Version  string
+	Local    []byte
+	// Merged is the local copy with the upstream changes applied
+	Merged    []byte
+	Conflicts int
+	// LocalModified is true when the local copy differs from the upstream version it is based on
+	LocalModified bool
+	// NoBase is true when the upstream version the local copy is based on is unknown
+	NoBase bool
+
+	state *templateSyncState
+}
+
+// joinLines joins lines into text ending with a newline
+func joinLines(lines []string) []byte {
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// checkTemplateUpdate looks for a newer upstream base template, returning nil when the local
+// copy is current. Unless forced, upstream is checked at most once per templateCheckInterval
+// and skipped versions are not offered again.
+func checkTemplateUpdate(upstream *templateUpstream, force bool) (*templateUpdate, error) {
+	localPath, upstreamPath, _, err := templateSyncPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := loadTemplateSyncState()
+	if err != nil {
+		return nil, err
+	}
+	if !force && time.Since(state.CheckedAt) < templateCheckInterval {
+		return nil, nil
+	}
+
+	base, err := os.ReadFile(upstreamPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read upstream template: %v", err)
+	}
+
+	// Without the upstream copy a conditional request can't tell us anything
+	etag := state.ETag
+	if base == nil {
+		etag = ""
+	}
+	content, etag, notModified, err := upstream.fetch(etag)
+	if err != nil {
+		return nil, err
+	}
+
+	state.CheckedAt = time.Now()
+	if notModified {
+		return nil, saveTemplateSyncState(state)
+	}
+
+	version := templateVersion(content)
+	if version == state.Version && base != nil {
+		state.ETag = etag
+		return nil, saveTemplateSyncState(state)
+	}
+
+	local, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base template: %v", err)
+	}
+	if bytes.Equal(local, content) {
+		// Already identical, just remember the version
+		return nil, recordUpstreamTemplate(state, content, etag)
+	}
+
+	if version == state.SkippedVersion && !force {
+		return nil, saveTemplateSyncState(state)
+	}
+	if err := saveTemplateSyncState(state); err != nil {
+		return nil, err
+	}
+
+	update := &templateUpdate{
+		Upstream: content,
+		ETag:     etag,
+		Version:  version,
+		Local:    local,
+		state:    state,
+	}
+	if base == nil {
+		// Nothing to merge against, updating replaces the local copy
+		update.Merged = content
+		update.LocalModified = true
+		update.NoBase = true
+		return update, nil
+	}
+
+	merged, conflicts := merge3(splitLines(string(base)), splitLines(string(local)), splitLines(string(content)))
+	update.Merged = joinLines(merged)
+	update.Conflicts = conflicts
+	update.LocalModified = !bytes.Equal(local, base)
+	return update, nil
+}
+
+// apply writes the merged template and records the upstream version
+func (u *templateUpdate) apply() error {
+	if hasConflictMarkers(string(u.Merged)) {
+		return fmt.Errorf("the merged template still has conflicts")
+	}
+
+	localPath, _, _, err := templateSyncPaths()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(localPath, u.Merged, 0644); err != nil {
+		return fmt.Errorf("failed to write base template: %v", err)
+	}
+	return recordUpstreamTemplate(u.state, u.Upstream, u.ETag)
+}
+
+// keepLocal keeps the local template as it is, treating the upstream changes as seen
+func (u *templateUpdate) keepLocal() error {
+	return recordUpstreamTemplate(u.state, u.Upstream, u.ETag)
+}
+
+// skip stops offering this upstream version
+func (u *templateUpdate) skip() error {
+	u.state.SkippedVersion = u.Version
+	return saveTemplateSyncState(u.state)
+}
+
+// checkForTemplateUpdate looks for a newer base template in the background and offers it
+// the next time the configuration list is shown
+func (f *CreateJobForm) checkForTemplateUpdate(force bool) {
+	go func() {
+		update, err := checkTemplateUpdate(baseTemplateUpstream, force)
+		f.app.QueueUpdateDraw(func() {
+			if err != nil {
+				if force {
+					showError(f.app, f.currentPanel, fmt.Sprintf("Failed to check for template updates: %v", err))
+				}
+				return
+			}
+			if update == nil {
+				if force {
+					showMessage(f.app, f.currentPanel, "The base template is up to date")
+				}
+				return
+			}
+
+			f.pendingUpdate = update
+			if f.currentPanel == f.configList && f.draftPrompt == nil {
+				f.offerTemplateUpdate()
+			}
+		})
+	}()
+}
+
+// offerTemplateUpdate asks whether to review a pending base template update
+func (f *CreateJobForm) offerTemplateUpdate() {
+	update := f.pendingUpdate
+	if update == nil {
+		return
+	}
+	f.pendingUpdate = nil
+
+	text := fmt.Sprintf("A new version of the base template is available (%s).\n\n", update.Version)
+	switch {
+	case update.NoBase:
+		text += "The version your copy is based on is unknown, so updating replaces your copy."
+	case !update.LocalModified:
+		text += "Your copy has no local changes."
+	case update.Conflicts == 0:
+		text += "Your local changes merge cleanly with the new version."
+	default:
+		text += fmt.Sprintf("%d of your local changes conflict with the new version.", update.Conflicts)
+	}
+
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"Review", "Skip This Version", "Later"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			switch buttonLabel {
+			case "Review":
+				f.showTemplateMerge(update)
+			case "Skip This Version":
+				if err := update.skip(); err != nil {
+					showError(f.app, f.currentPanel, err.Error())
+					return
+				}
+				f.app.SetRoot(f.currentPanel, true)
+			default:
+				f.app.SetRoot(f.currentPanel, true)
+			}
+		})
+	f.app.SetRoot(modal, true)
+}
+
+// showTemplateMerge shows the changes an update makes to the local base template
+func (f *CreateJobForm) showTemplateMerge(update *templateUpdate) {
+	diffView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true)
+	diffView.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+
+	refresh := func() {
+		conflicts := strings.Count(string(update.Merged), conflictLocalMarker+"\n")
+		diffView.SetTitle(fmt.Sprintf("Base template update %s (%d conflicts)", update.Version, conflicts))
+
+		diff := unifiedDiff(splitLines(string(update.Local)), splitLines(string(update.Merged)), "base_apply.yaml (local)", "base_apply.yaml (updated)", 3)
+		if diff == "" {
+			diff = "No changes to your copy."
+		}
+		diffView.SetText(colorizeDiff(diff))
+		diffView.ScrollToBeginning()
+	}
+	refresh()
+
+	buttons := tview.NewForm().SetButtonsAlign(tview.AlignCenter)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(diffView, 0, 1, true).
+		AddItem(buttons, 3, 0, false)
+
+	done := func(message string) {
+		f.showConfigList()
+		showMessage(f.app, f.currentPanel, message)
+	}
+
+	editMerge := func() {
+		tmpFile, err := os.CreateTemp("", "kstool-merge-*.yaml")
+		if err != nil {
+			showError(f.app, layout, fmt.Sprintf("Failed to create temporary file: %v", err))
+			return
+		}
+		defer os.Remove(tmpFile.Name())
+
+		if _, err := tmpFile.Write(update.Merged); err != nil {
+			tmpFile.Close()
+			showError(f.app, layout, fmt.Sprintf("Failed to write to temporary file: %v", err))
+			return
+		}
+		tmpFile.Close()
+
+		if err := EditFile(f.app, tmpFile.Name(), false); err != nil {
+			showError(f.app, layout, err.Error())
+			return
+		}
+
+		edited, err := os.ReadFile(tmpFile.Name())
+		if err != nil {
+			showError(f.app, layout, fmt.Sprintf("Failed to read edited file: %v", err))
+			return
+		}
+		update.Merged = edited
+		refresh()
+	}
+
+	buttons.
+		AddButton("Apply Update", func() {
+			if hasConflictMarkers(string(update.Merged)) {
+				showError(f.app, layout, "Resolve the conflicts with Edit Merge first")
+				return
+			}
+			if err := update.apply(); err != nil {
+				showError(f.app, layout, fmt.Sprintf("Failed to update base template: %v", err))
+				return
+			}
+			done("Base template updated")
+		}).
+		AddButton("Edit Merge", editMerge).
+		AddButton("Keep Mine", func() {
+			if err := update.keepLocal(); err != nil {
+				showError(f.app, layout, fmt.Sprintf("Failed to record template version: %v", err))
+				return
+			}
+			done("Kept your base template")
+		}).
+		AddButton("Back", func() {
+			f.app.SetRoot(f.currentPanel, true)
+		})
+
+	// Tab moves between the diff and the buttons
+	diffView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyTab:
+			f.app.SetFocus(buttons)
+			return nil
+		case tcell.KeyEscape:
+			f.app.SetRoot(f.currentPanel, true)
+			return nil
+		}
+		return event
+	})
+	buttons.SetCancelFunc(func() {
+		f.app.SetFocus(diffView)
+	})
+
+	f.app.SetRoot(layout, true)
+}
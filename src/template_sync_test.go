@@ -0,0 +1,204 @@
+package src
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeUpstream serves a base_apply.yaml in place of GitHub, answering conditional requests
+type fakeUpstream struct {
+	content  string
+	requests int
+}
+
+func (u *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.requests++
+	if r.URL.Path != "/base_apply.yaml" {
+		http.NotFound(w, r)
+		return
+	}
+	etag := `"` + templateVersion([]byte(u.content)) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write([]byte(u.content))
+}
+
+// setupTemplateSync points HOME at a temporary directory holding the local base template
+// and returns an upstream served from a local server
+func setupTemplateSync(t *testing.T, local string, fake *fakeUpstream) *templateUpstream {
+	t.Setenv("HOME", t.TempDir())
+	localPath, _, _, err := templateSyncPaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localPath, []byte(local), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return &templateUpstream{URL: server.URL + "/base_apply.yaml", Client: server.Client()}
+}
+
+// readFile returns the content of a file or fails the test
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+const (
+	templateV1 = "kind: Job\nimage: ubuntu:20.04\ngpus: 1\n"
+	templateV2 = "kind: Job\nimage: ubuntu:22.04\ngpus: 1\n"
+)
+
+func TestTemplateUpdateCheck(t *testing.T) {
+	fake := &fakeUpstream{content: templateV1}
+	upstream := setupTemplateSync(t, templateV1, fake)
+	localPath, upstreamPath, _, _ := templateSyncPaths()
+
+	// A local copy identical to upstream is recorded as its version
+	update, err := checkTemplateUpdate(upstream, true)
+	if err != nil || update != nil {
+		t.Fatalf("expected no update for an identical template, got %v, %v", update, err)
+	}
+	state, err := loadTemplateSyncState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Version != templateVersion([]byte(templateV1)) || state.ETag == "" {
+		t.Errorf("upstream version wasn't recorded: %+v", state)
+	}
+	if readFile(t, upstreamPath) != templateV1 {
+		t.Errorf("upstream copy wasn't stored")
+	}
+
+	// Unforced checks wait for the check interval
+	requests := fake.requests
+	if update, err := checkTemplateUpdate(upstream, false); err != nil || update != nil {
+		t.Fatalf("expected no check within the interval, got %v, %v", update, err)
+	}
+	if fake.requests != requests {
+		t.Errorf("upstream was requested within the check interval")
+	}
+
+	// Unchanged upstream answers the conditional request with 304
+	if update, err := checkTemplateUpdate(upstream, true); err != nil || update != nil {
+		t.Fatalf("expected no update for an unchanged upstream, got %v, %v", update, err)
+	}
+
+	// A new upstream version is merged with the local edits
+	if err := os.WriteFile(localPath, []byte(templateV1+"# local note\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake.content = templateV2
+	update, err = checkTemplateUpdate(upstream, true)
+	if err != nil || update == nil {
+		t.Fatalf("expected an update, got %v, %v", update, err)
+	}
+	if !update.LocalModified || update.NoBase || update.Conflicts != 0 {
+		t.Errorf("unexpected update %+v", update)
+	}
+	want := templateV2 + "# local note\n"
+	if string(update.Merged) != want {
+		t.Errorf("merged template:\n%s\nwant:\n%s", update.Merged, want)
+	}
+
+	if err := update.apply(); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, localPath) != want {
+		t.Errorf("merged template wasn't written")
+	}
+	if readFile(t, upstreamPath) != templateV2 {
+		t.Errorf("new upstream version wasn't stored")
+	}
+	if state, _ := loadTemplateSyncState(); state.Version != templateVersion([]byte(templateV2)) {
+		t.Errorf("new upstream version wasn't recorded: %+v", state)
+	}
+}
+
+func TestTemplateUpdateConflictAndSkip(t *testing.T) {
+	fake := &fakeUpstream{content: templateV1}
+	upstream := setupTemplateSync(t, templateV1, fake)
+	localPath, _, _, _ := templateSyncPaths()
+	if _, err := checkTemplateUpdate(upstream, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both sides change the image
+	if err := os.WriteFile(localPath, []byte("kind: Job\nimage: pytorch:2.1\ngpus: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake.content = templateV2
+	update, err := checkTemplateUpdate(upstream, true)
+	if err != nil || update == nil {
+		t.Fatalf("expected an update, got %v, %v", update, err)
+	}
+	if update.Conflicts != 1 || !hasConflictMarkers(string(update.Merged)) {
+		t.Fatalf("expected one conflict, got %d:\n%s", update.Conflicts, update.Merged)
+	}
+	if err := update.apply(); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Errorf("applying a merge with conflicts should fail, got %v", err)
+	}
+
+	// A skipped version is only offered again when forced
+	if err := update.skip(); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := loadTemplateSyncState()
+	state.CheckedAt = state.CheckedAt.Add(-2 * templateCheckInterval)
+	if err := saveTemplateSyncState(state); err != nil {
+		t.Fatal(err)
+	}
+	if update, err := checkTemplateUpdate(upstream, false); err != nil || update != nil {
+		t.Errorf("skipped version was offered again: %v, %v", update, err)
+	}
+	if update, err := checkTemplateUpdate(upstream, true); err != nil || update == nil {
+		t.Errorf("forced check didn't offer the skipped version: %v, %v", update, err)
+	}
+}
+
+func TestTemplateUpdateWithoutBase(t *testing.T) {
+	fake := &fakeUpstream{content: templateV2}
+	upstream := setupTemplateSync(t, templateV1, fake)
+
+	// Without a stored upstream version the update replaces the local copy
+	update, err := checkTemplateUpdate(upstream, true)
+	if err != nil || update == nil {
+		t.Fatalf("expected an update, got %v, %v", update, err)
+	}
+	if !update.NoBase || string(update.Merged) != templateV2 {
+		t.Errorf("unexpected update %+v", update)
+	}
+
+	if err := update.keepLocal(); err != nil {
+		t.Fatal(err)
+	}
+	localPath, upstreamPath, _, _ := templateSyncPaths()
+	if readFile(t, localPath) != templateV1 || readFile(t, upstreamPath) != templateV2 {
+		t.Errorf("keeping the local copy should only record the upstream version")
+	}
+}
+
+func TestTemplateUpdateServerError(t *testing.T) {
+	upstream := setupTemplateSync(t, templateV1, &fakeUpstream{content: templateV1})
+	upstream.URL = strings.TrimSuffix(upstream.URL, "base_apply.yaml") + "missing.yaml"
+
+	if _, err := checkTemplateUpdate(upstream, true); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a download error, got %v", err)
+	}
+}
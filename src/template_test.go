@@ -0,0 +1,118 @@
+package src
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// want lists the placeholders as name, operator, argument, line and column
+		want []string
+	}{
+		{name: "undeclared variable", content: "cd ${HOME}/x && echo ${JOB_COMPLETION_INDEX}"},
+		{name: "annotated variable", content: "# kstool: IMAGE\nimage: ${IMAGE}", want: []string{"IMAGE   2:8"}},
+		{name: "declared by another occurrence", content: "a: ${A:-1}\nb: ${A}", want: []string{"A :- 1 1:4", "A   2:4"}},
+		{name: "default", content: "${A:-d}", want: []string{"A :- d 1:1"}},
+		{name: "empty default", content: "${A:-}", want: []string{"A :-  1:1"}},
+		{name: "default when unset", content: "${A-d}", want: []string{"A - d 1:1"}},
+		{name: "required", content: "${A:?m}", want: []string{"A :? m 1:1"}},
+		{name: "required when unset", content: "${A?m}", want: []string{"A ? m 1:1"}},
+		{name: "alternate", content: "${A:+x}", want: []string{"A :+ x 1:1"}},
+		{name: "alternate when set", content: "${A+x}", want: []string{"A + x 1:1"}},
+		{name: "balanced braces in default", content: "${A:-echo {a,b}}", want: []string{"A :- echo {a,b} 1:1"}},
+		{name: "nested placeholder", content: "${A:-${B:-x}}", want: []string{"A :- ${B:-x} 1:1", "B :- x 1:6"}},
+		{name: "undeclared variable in default", content: "${A:-${HOME}/x}", want: []string{"A :- ${HOME}/x 1:1"}},
+		{name: "escaped placeholder", content: "$${A:-1}"},
+		{name: "shell forms", content: "$A ${#A} ${A[0]} ${1}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseTemplate([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("ParseTemplate: %v", err)
+			}
+			var got []string
+			for _, p := range parsed.Placeholders {
+				got = append(got, fmt.Sprintf("%s %s %s %d:%d", p.Name, p.Op, p.Arg, p.Line, p.Column))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("placeholders = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTemplateUnterminated(t *testing.T) {
+	for _, content := range []string{"a: ${A", "a: ${A:-x", "a: ${A:-{x}"} {
+		if _, err := ParseTemplate([]byte(content)); err == nil || !strings.Contains(err.Error(), "unterminated") {
+			t.Errorf("ParseTemplate(%q) = %v, want an unterminated placeholder error", content, err)
+		}
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	values := map[string]string{"SET": "v", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		content string
+		want    string
+		err     string
+	}{
+		{content: "${SET:-d} ${EMPTY:-d} ${UNSET:-d}", want: "v d d"},
+		{content: "${SET-d} ${EMPTY-d} ${UNSET-d}", want: "v  d"},
+		{content: "${SET:+x} ${EMPTY:+x} ${UNSET:+x}", want: "x  "},
+		{content: "${SET+x} ${EMPTY+x} ${UNSET+x}", want: "x x "},
+		{content: "${SET:?m} ${EMPTY?m}", want: "v "},
+		{content: "${EMPTY:?empty}", err: "EMPTY: empty"},
+		{content: "${UNSET?unset}", err: "UNSET: unset"},
+		{content: "${UNSET:?}", err: "parameter null or not set"},
+		{content: "# kstool: SET\n${SET} ${UNSET:-}${UNSET}", want: "# kstool: SET\nv "},
+		{content: "${UNSET:-${SET:-d}-${HOME}}", want: "v-${HOME}"},
+		{content: "$${SET:-d} $SET ${HOME}", want: "${SET:-d} $SET ${HOME}"},
+	}
+
+	for _, tt := range tests {
+		parsed, err := ParseTemplate([]byte(tt.content))
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q): %v", tt.content, err)
+		}
+		got, err := parsed.Render(lookup)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Render(%q) error = %v, want %q", tt.content, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Render(%q): %v", tt.content, err)
+		} else if string(got) != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateVariables(t *testing.T) {
+	parsed, err := ParseTemplate([]byte("a: ${B:-${A:-1}}\nb: ${C:?needed} ${HOME}\nc: ${A:-2} ${D}\n# kstool: D type=int\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TemplateVariable{
+		{Name: "B", Default: "1", Line: 1},
+		{Name: "A", Default: "1", Line: 1},
+		{Name: "C", Required: true, Line: 2},
+		{Name: "D", Line: 3},
+	}
+	if got := parsed.Variables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/suchun/kstool/config"
+)
+
+const (
+	templatesDir = "templates"
+
+	// DefaultTemplateName refers to the original ~/.kstool/base_apply.yaml template
+	DefaultTemplateName = "base_apply"
+)
+
+// templatePath returns the path of a named template.
+// The default template keeps living at ~/.kstool/base_apply.yaml so existing setups keep working.
+func templatePath(name string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+
+	if name == "" || name == DefaultTemplateName {
+		return filepath.Join(homeDir, configDir, "base_apply.yaml"), nil
+	}
+	return filepath.Join(homeDir, configDir, templatesDir, name+".yaml"), nil
+}
+
+// readTemplate reads the content of a named template
+func readTemplate(name string) ([]byte, error) {
+	path, err := templatePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) && templateDisplayName(name) == DefaultTemplateName {
+		// Not installed yet, e.g. when linting before KSTool was first opened
+		return config.BaseTemplate, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %v", templateDisplayName(name), err)
+	}
+	return content, nil
+}
+
+// listTemplates returns the default template followed by the templates in ~/.kstool/templates
+func listTemplates() ([]string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %v", err)
+	}
+
+	templates := []string{DefaultTemplateName}
+
+	files, err := os.ReadDir(filepath.Join(homeDir, configDir, templatesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return templates, nil
+		}
+		return nil, fmt.Errorf("failed to read templates directory: %v", err)
+	}
+
+	var names []string
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".yaml")
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".yaml") && name != DefaultTemplateName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return append(templates, names...), nil
+}
+
+// templateDisplayName returns the name shown for a template, treating an empty name as the default
+func templateDisplayName(name string) string {
+	if name == "" {
+		return DefaultTemplateName
+	}
+	return name
+}
+
+// showTemplatePicker lets the user choose the template for a new configuration.
+// The picker is skipped when only the default template exists.
+func (f *CreateJobForm) showTemplatePicker(onSelect func(template string)) {
+	templates, err := listTemplates()
+	if err != nil {
+		showError(f.app, f.currentPanel, fmt.Sprintf("Failed to load templates: %v", err))
+		return
+	}
+
+	if len(templates) == 1 {
+		onSelect(templates[0])
+		return
+	}
+
+	list := tview.NewList()
+	list.SetBorder(true).
+		SetTitle("Select Template").
+		SetTitleAlign(tview.AlignLeft)
+
+	for _, name := range templates {
+		templateName := name // Create a new variable to avoid closure issues
+		path, _ := templatePath(templateName)
+		list.AddItem(templateName, path, 0, func() {
+			onSelect(templateName)
+		})
+	}
+
+	list.AddItem("Back", "Return to configuration list", 'q', func() {
+		f.app.SetRoot(f.currentPanel, true)
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyEscape:
+			f.app.SetRoot(f.currentPanel, true)
+			return nil
+		case event.Key() == tcell.KeyRune && event.Rune() == 'j':
+			if list.GetCurrentItem() < list.GetItemCount()-1 {
+				list.SetCurrentItem(list.GetCurrentItem() + 1)
+			}
+			return nil
+		case event.Key() == tcell.KeyRune && event.Rune() == 'k':
+			if list.GetCurrentItem() > 0 {
+				list.SetCurrentItem(list.GetCurrentItem() - 1)
+			}
+			return nil
+		}
+		return event
+	})
+
+	f.app.SetRoot(list, true)
+}
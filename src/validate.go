@@ -0,0 +1,297 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// defaultRequiredLabels must be present on every job so KSTool can tell who owns it
+var defaultRequiredLabels = []string{userLabel}
+
+var (
+	// structFieldPattern extracts the field of a JSON type error, e.g. "Go struct field JobSpec.spec.backoffLimit"
+	structFieldPattern = regexp.MustCompile(`Go struct field [A-Za-z0-9_]+\.(\S+)`)
+	// quotedFieldPattern extracts a field path quoted in an error message
+	quotedFieldPattern = regexp.MustCompile(`"\.?((?:metadata|spec)[^"]*)"`)
+	// mapKeyPattern matches non-numeric map keys in field paths, e.g. limits[memory]
+	mapKeyPattern = regexp.MustCompile(`\[([^\]0-9][^\]]*)\]`)
+	// indexPattern matches list indexes in field paths, e.g. containers[0]
+	indexPattern = regexp.MustCompile(`\[[0-9]+\]`)
+)
+
+// FieldError is a problem found while validating a rendered manifest
+type FieldError struct {
+	// Field is the path of the offending field, empty for problems with the whole manifest
+	Field   string
+	Message string
+	// Variables lists the template variables that feed the field
+	Variables []string
+}
+
+func (e FieldError) String() string {
+	text := e.Message
+	if e.Field != "" {
+		text = e.Field + ": " + text
+	}
+	if len(e.Variables) > 0 {
+		text += " (" + strings.Join(e.Variables, ", ") + ")"
+	}
+	return text
+}
+
+// formatFieldErrors lists validation problems one per line
+func formatFieldErrors(problems []FieldError) string {
+	lines := make([]string, len(problems))
+	for i, problem := range problems {
+		lines[i] = problem.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// normalizeFieldPath converts API field paths such as ".spec.limits[memory]" to "spec.limits.memory"
+func normalizeFieldPath(path string) string {
+	path = strings.TrimPrefix(path, ".")
+	return mapKeyPattern.ReplaceAllString(path, ".$1")
+}
+
+// variablePaths returns the field paths at which each template variable is used
+func variablePaths(content []byte) map[string][]string {
+	paths := make(map[string][]string)
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return paths
+	}
+
+	declared := declaredVariables(content)
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				walk(child, fmt.Sprintf("%s[%d]", path, i))
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				childPath := node.Content[i].Value
+				if path != "" {
+					childPath = path + "." + childPath
+				}
+				walk(node.Content[i+1], childPath)
+			}
+		case yaml.ScalarNode:
+			if parsed, err := parseTemplate([]byte(node.Value), declared); err == nil {
+				for _, placeholder := range parsed.Placeholders {
+					paths[placeholder.Name] = append(paths[placeholder.Name], path)
+				}
+			}
+		}
+	}
+	walk(&root, "")
+	return paths
+}
+
+// attributeFieldErrors fills in the variables feeding each field error
+func attributeFieldErrors(problems []FieldError, paths map[string][]string) {
+	for i := range problems {
+		field := normalizeFieldPath(problems[i].Field)
+		if field == "" || len(problems[i].Variables) > 0 {
+			continue
+		}
+		// JSON type errors name fields without list indexes
+		withoutIndexes := !strings.Contains(field, "[")
+		for name, variablePaths := range paths {
+			for _, path := range variablePaths {
+				if withoutIndexes {
+					path = indexPattern.ReplaceAllString(path, "")
+				}
+				if field == path || strings.HasPrefix(field, path+".") || strings.HasPrefix(field, path+"[") {
+					problems[i].Variables = append(problems[i].Variables, name)
+					break
+				}
+			}
+		}
+	}
+}
+
+// checkQuantities parses the resource quantities of every container of a job
+func checkQuantities(obj *unstructured.Unstructured) []FieldError {
+	var problems []FieldError
+	for _, kind := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", kind)
+		for i, container := range containers {
+			containerMap, ok := container.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, section := range []string{"limits", "requests"} {
+				quantities, _, _ := unstructured.NestedMap(containerMap, "resources", section)
+				for name, value := range quantities {
+					text := fmt.Sprint(value)
+					if _, err := resource.ParseQuantity(text); err != nil {
+						problems = append(problems, FieldError{
+							Field:   fmt.Sprintf("spec.template.spec.%s[%d].resources.%s.%s", kind, i, section, name),
+							Message: fmt.Sprintf("invalid quantity %q", text),
+						})
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// checkRequiredLabels reports required labels missing from the job
+func checkRequiredLabels(obj *unstructured.Unstructured, required []string) []FieldError {
+	var problems []FieldError
+	labels := obj.GetLabels()
+	for _, label := range required {
+		if labels[label] == "" {
+			problems = append(problems, FieldError{
+				Field:   "metadata.labels." + label,
+				Message: "required label is missing",
+			})
+		}
+	}
+	return problems
+}
+
+// checkJobSchema strictly decodes the object into a batchv1.Job
+func checkJobSchema(obj *unstructured.Unstructured) []FieldError {
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return []FieldError{{Message: fmt.Sprintf("failed to encode manifest: %v", err)}}
+	}
+
+	decoder := json.NewSerializerWithOptions(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme, json.SerializerOptions{Strict: true})
+	_, _, err = decoder.Decode(data, nil, &batchv1.Job{})
+	if err == nil {
+		return nil
+	}
+
+	var problems []FieldError
+	if strictErr, ok := runtime.AsStrictDecodingError(err); ok {
+		for _, fieldErr := range strictErr.Errors() {
+			problem := FieldError{Message: fieldErr.Error()}
+			if matches := quotedFieldPattern.FindStringSubmatch(fieldErr.Error()); matches != nil {
+				problem.Field = matches[1]
+			}
+			problems = append(problems, problem)
+		}
+		return problems
+	}
+
+	problem := FieldError{Message: err.Error()}
+	if matches := structFieldPattern.FindStringSubmatch(err.Error()); matches != nil {
+		problem.Field = matches[1]
+	}
+	return append(problems, problem)
+}
+
+// checkServerSide submits the object as a dry run so the API server validates it against its OpenAPI schema
+func checkServerSide(ctx context.Context, clients *KubeClients, obj *unstructured.Unstructured) []FieldError {
+	_, err := clients.createObject(ctx, obj.DeepCopy(), metav1.CreateOptions{
+		DryRun:          []string{metav1.DryRunAll},
+		FieldValidation: metav1.FieldValidationStrict,
+	})
+	if err == nil {
+		return nil
+	}
+
+	var statusErr *apierrors.StatusError
+	if !errors.As(err, &statusErr) {
+		// The cluster is unreachable; submission will report it
+		return nil
+	}
+
+	var problems []FieldError
+	if details := statusErr.ErrStatus.Details; details != nil {
+		for _, cause := range details.Causes {
+			problem := FieldError{Field: cause.Field, Message: cause.Message}
+			if problem.Field == "" {
+				if matches := quotedFieldPattern.FindStringSubmatch(cause.Message); matches != nil {
+					problem.Field = matches[1]
+				}
+			}
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) == 0 {
+		problems = append(problems, FieldError{Message: statusErr.ErrStatus.Message})
+	}
+	return problems
+}
+
+// validateManifest renders the configuration and validates the resulting manifest.
+// Problems are attributed to the template variables that produced the offending fields.
+func validateManifest(ctx context.Context, clients *KubeClients, config Config) []FieldError {
+	content, err := readTemplate(config.Template)
+	if err != nil {
+		return []FieldError{{Message: err.Error()}}
+	}
+
+	manifest, err := renderTemplate(content, config)
+	if err != nil {
+		var renderErr *RenderError
+		if errors.As(err, &renderErr) {
+			return []FieldError{{Message: renderErr.Message, Variables: []string{renderErr.Name}}}
+		}
+		return []FieldError{{Message: err.Error()}}
+	}
+	if manifest, err = applyOverlay(manifest, config.Overlay); err != nil {
+		return []FieldError{{Message: err.Error()}}
+	}
+
+	objects, err := decodeManifest(manifest)
+	if err != nil {
+		return []FieldError{{Message: err.Error()}}
+	}
+
+	problems := validateObjects(ctx, clients, objects)
+	attributeFieldErrors(problems, variablePaths(content))
+	return problems
+}
+
+// validateObjects validates the Jobs among the objects of a manifest
+func validateObjects(ctx context.Context, clients *KubeClients, objects []*unstructured.Unstructured) []FieldError {
+	requiredLabels := defaultRequiredLabels
+	if settings, err := loadSettings(); err == nil && settings.RequiredLabels != nil {
+		requiredLabels = settings.RequiredLabels
+	}
+
+	var problems []FieldError
+	for _, obj := range objects {
+		if obj.GroupVersionKind() != batchv1.SchemeGroupVersion.WithKind("Job") {
+			continue
+		}
+
+		objProblems := checkQuantities(obj)
+		objProblems = append(objProblems, checkRequiredLabels(obj, requiredLabels)...)
+		// Decoding would fail on the same quantities again with less context
+		if len(objProblems) == 0 {
+			objProblems = checkJobSchema(obj)
+		}
+		if len(objProblems) == 0 && clients != nil {
+			objProblems = checkServerSide(ctx, clients, obj)
+		}
+		problems = append(problems, objProblems...)
+	}
+	return problems
+}